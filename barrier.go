@@ -0,0 +1,112 @@
+package maptester
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// startBarrier holds the worker threads until all of them are ready,
+// so the timing does not include the goroutines startup
+type startBarrier struct {
+	ready    sync.WaitGroup
+	start    chan struct{}
+	released time.Time
+}
+
+func newStartBarrier(nbWorkers int) *startBarrier {
+	b := &startBarrier{start: make(chan struct{})}
+	b.ready.Add(nbWorkers)
+	return b
+}
+
+// await is called by a worker when ready, it returns when the barrier is released
+func (b *startBarrier) await() {
+	b.ready.Done()
+	<-b.start
+}
+
+// waitReady waits for all the workers to be ready
+func (b *startBarrier) waitReady() {
+	b.ready.Wait()
+}
+
+// release waits for all the workers to be ready, then starts them
+func (b *startBarrier) release() time.Time {
+	b.waitReady()
+	b.released = time.Now()
+	close(b.start)
+	return b.released
+}
+
+// workerSkews are the start and finish times of the worker threads after the release of their barrier.
+// The start skew is the latest start, the finish skews the spreads of the finish times of the writers and the readers.
+type workerSkews struct {
+	mutex        sync.Mutex
+	startSkew    time.Duration
+	writeFinish  durationRange
+	readFinish   durationRange
+	nbWriteSkews int
+	nbReadSkews  int
+}
+
+type durationRange struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (r *durationRange) add(d time.Duration, first bool) {
+	if first || d < r.min {
+		r.min = d
+	}
+	if first || d > r.max {
+		r.max = d
+	}
+}
+
+func (r *durationRange) spread() time.Duration {
+	return r.max - r.min
+}
+
+func (ws *workerSkews) reset() {
+	ws.mutex.Lock()
+	ws.startSkew = 0
+	ws.writeFinish = durationRange{}
+	ws.readFinish = durationRange{}
+	ws.nbWriteSkews = 0
+	ws.nbReadSkews = 0
+	ws.mutex.Unlock()
+}
+
+func (ws *workerSkews) add(writer bool, start, finish time.Duration) {
+	ws.mutex.Lock()
+	if start > ws.startSkew {
+		ws.startSkew = start
+	}
+	if writer {
+		ws.writeFinish.add(finish, ws.nbWriteSkews == 0)
+		ws.nbWriteSkews++
+	} else {
+		ws.readFinish.add(finish, ws.nbReadSkews == 0)
+		ws.nbReadSkews++
+	}
+	ws.mutex.Unlock()
+}
+
+func (ws *workerSkews) String() string {
+	return fmt.Sprintf("start skew %v, %d writers finished from %v to %v, %d readers from %v to %v",
+		ws.startSkew, ws.nbWriteSkews, ws.writeFinish.min, ws.writeFinish.max,
+		ws.nbReadSkews, ws.readFinish.min, ws.readFinish.max)
+}
+
+// startWorker runs the work of a writer or reader thread once the barrier is released,
+// and marks it done in the wait group
+func (mp *MapPerfTestResult) startWorker(barrier *startBarrier, writer bool, wg *sync.WaitGroup, work func()) {
+	go func() {
+		barrier.await()
+		start := time.Since(barrier.released)
+		work()
+		mp.skews.add(writer, start, time.Since(barrier.released))
+		wg.Done()
+	}()
+}
@@ -0,0 +1,51 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartBarrier(t *testing.T) {
+	mp := new(MapPerfTestResult)
+	barrier := newStartBarrier(4)
+	wg := new(sync.WaitGroup)
+	started := int32(0)
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		mp.startWorker(barrier, i == 0, wg, func() {
+			atomic.AddInt32(&started, 1)
+			time.Sleep(time.Millisecond)
+		})
+	}
+	barrier.waitReady()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&started))
+	released := barrier.release()
+	wg.Wait()
+	assert.Equal(t, int32(4), started)
+	assert.Equal(t, released, barrier.released)
+	assert.Equal(t, 1, mp.skews.nbWriteSkews)
+	assert.Equal(t, 3, mp.skews.nbReadSkews)
+	assert.Equal(t, time.Duration(0), mp.skews.writeFinish.spread())
+	assert.True(t, mp.skews.readFinish.min >= time.Millisecond)
+	assert.True(t, mp.skews.startSkew < mp.skews.readFinish.min)
+	mp.skews.reset()
+	assert.Equal(t, 0, mp.skews.nbReadSkews)
+}
+
+func TestTestSkews(t *testing.T) {
+	size := 1000
+	im, report := newTestDataSet(size)
+	for _, mapTypeName := range []string{"basic", "RWMutex"} {
+		mp := &MapPerfTestResult{runConf: newTestRunConfiguration(size, 2, 3, 500), mapTypeName: mapTypeName}
+		mp.fill(report)
+		mp.testConcurrentMap(im)
+		assert.Equal(t, 0, mp.totalErrors(), mapTypeName)
+		assert.True(t, mp.setupDuration > 0, mapTypeName)
+		assert.Equal(t, 3, mp.skews.nbReadSkews, mapTypeName)
+		assert.True(t, mp.skews.readFinish.max <= mp.execDuration(), mapTypeName)
+	}
+}
@@ -0,0 +1,234 @@
+package maptester
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/google/logger"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CatalogEntry describes a data set present in the gen data dir
+type CatalogEntry struct {
+	DataSetName   string    `json:"dataSetName"`
+	KeyType       string    `json:"keyType"`
+	ConflictRatio float32   `json:"conflictRatio"`
+	ValueSize     string    `json:"valueSize"`
+	Size          int       `json:"size"`
+	Seed          int64     `json:"seed"`
+	FormatVersion int       `json:"formatVersion"`
+	Compression   string    `json:"compression,omitempty"`
+	DataFile      string    `json:"dataFile"`
+	FileSize      int64     `json:"fileSize"`
+	Sha256        string    `json:"sha256"`
+	Created       time.Time `json:"created"`
+	Imported      bool      `json:"imported,omitempty"`
+}
+
+// DataCatalog is the JSON manifest of the data sets maintained by gen, clean and import
+type DataCatalog struct {
+	DataSets []*CatalogEntry `json:"dataSets"`
+}
+
+// Catalog updates are read, modify, write of the whole file from parallel generation workers
+var catalogMutex sync.Mutex
+
+func getCatalogFilename() string {
+	return filepath.Join(utils.GetGenDataDir(), "catalog.json")
+}
+
+func readCatalog() *DataCatalog {
+	catalog := &DataCatalog{DataSets: make([]*CatalogEntry, 0)}
+	filename := getCatalogFilename()
+	if !utils.FileExists(filename) {
+		return catalog
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(data, catalog)
+	}
+	if err != nil {
+		logger.Errorf("Cannot read data catalog %s due to %v", filename, err)
+	}
+	return catalog
+}
+
+func (catalog *DataCatalog) write() {
+	sort.Slice(catalog.DataSets, func(i, j int) bool {
+		return catalog.DataSets[i].DataSetName < catalog.DataSets[j].DataSetName
+	})
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	utils.ExitOnError(err)
+	tmpFilename := getCatalogFilename() + ".tmp"
+	utils.ExitOnError(ioutil.WriteFile(tmpFilename, data, 0644))
+	utils.ExitOnError(os.Rename(tmpFilename, getCatalogFilename()))
+}
+
+func (catalog *DataCatalog) find(dataSetName string) *CatalogEntry {
+	for _, entry := range catalog.DataSets {
+		if entry.DataSetName == dataSetName {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (catalog *DataCatalog) remove(dataSetName string) bool {
+	for idx, entry := range catalog.DataSets {
+		if entry.DataSetName == dataSetName {
+			catalog.DataSets = append(catalog.DataSets[:idx], catalog.DataSets[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func isCataloged(dataSetName string) bool {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	return readCatalog().find(dataSetName) != nil
+}
+
+// newCatalogEntry describes the data file of the data configuration using its header and content
+func newCatalogEntry(dc *DataConfiguration) (*CatalogEntry, error) {
+	dataFilename := findDataFilename(dc.GetDataFileName(), dc.size)
+	r, err := openDataFile(dataFilename)
+	if err != nil {
+		return nil, err
+	}
+	entry := &CatalogEntry{
+		DataSetName:   dc.GetDataSetName(),
+		KeyType:       dc.keyType,
+		ConflictRatio: dc.conflictRatio,
+		ValueSize:     dc.valueSize.Name(),
+		Size:          dc.size,
+		FormatVersion: r.version,
+		DataFile:      filepath.Base(dataFilename),
+		Created:       time.Now(),
+		Imported:      dc.imported,
+	}
+	if r.header != nil {
+		entry.Seed = r.header.Seed
+		entry.Compression = r.header.Compression
+	}
+	r.close()
+	entry.FileSize, entry.Sha256, err = fileSha256(dataFilename)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func fileSha256(filename string) (int64, string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	defer utils.CloseFile(file)
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// addToCatalog adds or replaces the catalog entry of the data set
+func addToCatalog(dc *DataConfiguration) {
+	entry, err := newCatalogEntry(dc)
+	if err != nil {
+		logger.Errorf("Cannot add data set %s to the catalog due to %v", dc.GetDataSetName(), err)
+		return
+	}
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	catalog := readCatalog()
+	catalog.remove(entry.DataSetName)
+	catalog.DataSets = append(catalog.DataSets, entry)
+	catalog.write()
+}
+
+func removeFromCatalog(dataSetName string) {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	if !utils.FileExists(getCatalogFilename()) {
+		return
+	}
+	catalog := readCatalog()
+	if catalog.remove(dataSetName) {
+		catalog.write()
+	}
+}
+
+// catalogStatus returns ok, old format when the data file is still readable in the version 1 format
+// without header, or why the data set of the configuration cannot be used as is
+func catalogStatus(dc *DataConfiguration, entry *CatalogEntry) string {
+	dataFilename := findDataFilename(dc.GetDataFileName(), dc.size)
+	fileInfo, err := os.Stat(dataFilename)
+	if err != nil || !utils.FileExists(getReportFilename(dc.GetDataFileName(), dc.size)) {
+		return "missing"
+	}
+	switch {
+	case entry == nil:
+		return "stale: not in catalog"
+	case entry.DataFile != filepath.Base(dataFilename) || entry.FileSize != fileInfo.Size():
+		return "stale: file changed since cataloged"
+	case entry.FormatVersion == DataFileVersion1:
+		// No header, so no seed to check
+		return "old format"
+	case entry.FormatVersion != DataFileVersion2:
+		return fmt.Sprintf("stale: unreadable format version %d", entry.FormatVersion)
+	case !dc.imported && entry.Seed != dc.seed():
+		return fmt.Sprintf("stale: seed %d instead of %d", entry.Seed, dc.seed())
+	}
+	return "ok"
+}
+
+// isReadyStatus is true for the catalog status of the data sets that can be used as is
+func isReadyStatus(status string) bool {
+	return status == "ok" || status == "old format"
+}
+
+// DisplayCatalog lists the data sets on disk and the missing or stale ones of the data configurations
+func DisplayCatalog() {
+	catalog := readCatalog()
+	configured := make(map[string]bool, len(DataConfigurations))
+	names := make([]string, 0, len(DataConfigurations))
+	for name, dc := range DataConfigurations {
+		// TODO: Support only int3d for now
+		if dc.keyType == KeyTypes[0] {
+			names = append(names, name)
+		}
+		configured[name] = true
+	}
+	sort.Strings(names)
+	nbOk := 0
+	for _, name := range names {
+		dc := DataConfigurations[name]
+		entry := catalog.find(name)
+		status := catalogStatus(dc, entry)
+		if isReadyStatus(status) {
+			nbOk++
+			fmt.Printf("%-40s %-14s %8.1f MB created %s\n", name, status, float64(entry.FileSize)/(1024*1024),
+				entry.Created.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%-40s %s\n", name, status)
+		}
+	}
+	for _, entry := range catalog.DataSets {
+		if !configured[entry.DataSetName] {
+			fmt.Printf("%-40s %-14s %8.1f MB created %s\n", entry.DataSetName, "not configured",
+				float64(entry.FileSize)/(1024*1024), entry.Created.Format("2006-01-02 15:04:05"))
+		}
+	}
+	fmt.Printf("%d data sets ready out of %d configured, %d in catalog %s\n",
+		nbOk, len(names), len(catalog.DataSets), getCatalogFilename())
+}
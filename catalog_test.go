@@ -0,0 +1,66 @@
+package maptester
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSha256(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hello.txt")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("hello\n"), 0644))
+	size, sha, err := fileSha256(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), size)
+	assert.Equal(t, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", sha)
+	_, _, err = fileSha256(filename + ".missing")
+	assert.Error(t, err)
+}
+
+func TestDataCatalogEntries(t *testing.T) {
+	catalog := &DataCatalog{DataSets: []*CatalogEntry{
+		{DataSetName: "a-10", Size: 10, Seed: 3, FormatVersion: DataFileVersion2},
+		{DataSetName: "b-10", Size: 10, Imported: true},
+	}}
+	data, err := json.Marshal(catalog)
+	assert.NoError(t, err)
+	read := new(DataCatalog)
+	assert.NoError(t, json.Unmarshal(data, read))
+	assert.Equal(t, int64(3), read.find("a-10").Seed)
+	assert.True(t, read.find("b-10").Imported)
+	assert.Nil(t, read.find("c-10"))
+	assert.True(t, read.remove("a-10"))
+	assert.False(t, read.remove("a-10"))
+	assert.Equal(t, 1, len(read.DataSets))
+}
+
+func TestCatalogStatus(t *testing.T) {
+	dc := &DataConfiguration{keyType: KeyTypes[0], conflictRatio: 0.5, valueSize: FixedValueSizeDistribution(12), size: 17}
+	dc.fillDataFileName()
+	assert.Equal(t, "missing", catalogStatus(dc, nil))
+	dataFilename := getDataFilename(dc.GetDataFileName(), dc.size)
+	reportFilename := getReportFilename(dc.GetDataFileName(), dc.size)
+	assert.NoError(t, ioutil.WriteFile(dataFilename, []byte("data"), 0644))
+	defer utils.DeleteFile(dataFilename)
+	assert.NoError(t, ioutil.WriteFile(reportFilename, []byte("report"), 0644))
+	defer utils.DeleteFile(reportFilename)
+
+	entry := &CatalogEntry{DataFile: filepath.Base(dataFilename), FileSize: 4, Seed: dc.seed(), FormatVersion: DataFileVersion2}
+	assert.Equal(t, "ok", catalogStatus(dc, entry))
+	assert.Equal(t, "stale: not in catalog", catalogStatus(dc, nil))
+	entry.Seed++
+	assert.Equal(t, fmt.Sprintf("stale: seed %d instead of %d", entry.Seed, dc.seed()), catalogStatus(dc, entry))
+	entry.FormatVersion = DataFileVersion1
+	entry.Seed = 0
+	assert.Equal(t, "old format", catalogStatus(dc, entry))
+	assert.True(t, isReadyStatus(catalogStatus(dc, entry)))
+	entry.FormatVersion = 3
+	assert.Equal(t, "stale: unreadable format version 3", catalogStatus(dc, entry))
+	assert.False(t, isReadyStatus(catalogStatus(dc, entry)))
+	entry.FileSize++
+	assert.Equal(t, "stale: file changed since cataloged", catalogStatus(dc, entry))
+}
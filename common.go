@@ -2,7 +2,10 @@ package maptester
 
 import (
 	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/gocarina/gocsv"
 	"github.com/google/logger"
+	"os"
 	"runtime"
 	"time"
 )
@@ -51,6 +54,41 @@ type MapPerfTestResult struct {
 	mapInitSize          int
 	nbExpectedMapEntries int
 	nbMapEntries         int
+	phases               phaseTimings
+	latencies            opLatencies
+	heap                 heapMeasure
+
+	// The exec durations of the repetitions, the errors of the runs before the last one,
+	// and the number of runs including the warmup and profiled ones
+	execStats          sampleStats
+	previousRunsErrors int
+	nbRuns             int
+
+	// The failure status of a test run in a child process, ok if empty
+	status string
+
+	// The profile files relative to the results directory, profiling during the profiled run
+	profileFiles []string
+	profiling    bool
+
+	// The operations done by threads running until stopped, with a test duration
+	targetDuration time.Duration
+	stopRequested  uint32
+	readOpsDone    int64
+	writeOpsDone   int64
+	mixedOpsDone   int64
+
+	// The duration of the map creation, not part of the exec duration
+	setupDuration time.Duration
+	// The start and finish times of the worker threads
+	skews workerSkews
+
+	// The harness choosing the keys of the read threads, see harnessMode
+	harness string
+
+	// The operations to replay, or the recorded ones if recordTrace
+	trace       *OpTrace
+	recordTrace bool
 
 	errorsKeyNotFound           int32
 	errorsKeyFound              int32
@@ -61,6 +99,17 @@ type MapPerfTestResult struct {
 	errorsSizeNotMatch          int32
 }
 
+// phaseTimings are the number of operations and the duration of each phase of a test.
+// In the interleaved scenario the write and read phases overlap.
+type phaseTimings struct {
+	writeOps int
+	readOps  int
+	mixedOps int
+	write    time.Duration
+	read     time.Duration
+	mixed    time.Duration
+}
+
 /********************************************
 MemUsage Functions
 *********************************************/
@@ -138,6 +187,44 @@ func (pr *PerfResult) display(name string) {
 		name, pr.nbLines, pr.execDuration(), pr.memDiff().TotalAlloc/(1024*1024))
 }
 
+/********************************************
+phaseTimings Functions
+*********************************************/
+
+func (pt *phaseTimings) setWrite(nbOps int, d time.Duration) {
+	pt.writeOps = nbOps
+	pt.write = d
+}
+
+func (pt *phaseTimings) setRead(nbOps int, d time.Duration) {
+	pt.readOps = nbOps
+	pt.read = d
+}
+
+func (pt *phaseTimings) setMixed(nbOps int, d time.Duration) {
+	pt.mixedOps = nbOps
+	pt.mixed = d
+}
+
+func opsPerSecond(nbOps int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0.0
+	}
+	return float64(nbOps) / d.Seconds()
+}
+
+func (pt *phaseTimings) writeThroughput() float64 {
+	return opsPerSecond(pt.writeOps, pt.write)
+}
+
+func (pt *phaseTimings) readThroughput() float64 {
+	return opsPerSecond(pt.readOps, pt.read)
+}
+
+func (pt *phaseTimings) mixedThroughput() float64 {
+	return opsPerSecond(pt.mixedOps, pt.mixed)
+}
+
 /********************************************
 MapPerfTestResult Functions
 *********************************************/
@@ -150,7 +237,13 @@ func (mp *MapPerfTestResult) extractPerfLineKey() PerfLineKey {
 	return PerfLineKey{
 		KeyType:              mp.runConf.dataConf.keyType,
 		ConflictRatio:        mp.runConf.dataConf.conflictRatio,
-		ValueSize:            mp.runConf.dataConf.valueSize,
+		ValueSize:            mp.runConf.dataConf.valueSize.Name(),
+		DataSize:             mp.runConf.dataConf.size,
+		WorkloadMix:          mp.runConf.mix.Name(),
+		Scenario:             mp.runConf.scenario,
+		GoMaxProcs:           mp.runConf.runtime.goMaxProcs,
+		GCPercent:            mp.runConf.runtime.gcPercent,
+		MemoryLimit:          mp.runConf.runtime.memoryLimit,
 		ReadWriteThreadRatio: mp.runConf.readWriteThreadRatio,
 		ReadWriteNbRatio:     mp.runConf.readWriteNbRatio,
 		MapTypeName:          mp.mapTypeName,
@@ -164,8 +257,26 @@ func (mp *MapPerfTestResult) extractPerfLineKey() PerfLineKey {
 	}
 }
 
+// readAllPerfLines returns the measurements of the complete lines of a results CSV by resume key
 func readAllPerfLines(maptestsFile string) map[PerfLineKey]PerfLineMeasurement {
 	result := make(map[PerfLineKey]PerfLineMeasurement)
+	perfFile, err := os.Open(maptestsFile)
+	if err != nil {
+		logger.Errorf("Cannot read perf lines of %s due to %v", maptestsFile, err)
+		return result
+	}
+	defer utils.CloseFile(perfFile)
+	err = gocsv.UnmarshalToCallback(perfFile, func(line PerfLine) {
+		if line.isFailed() {
+			// Run again
+			return
+		}
+		result[line.PerfLineKey.resumeKey()] = line.PerfLineMeasurement
+	})
+	if err != nil {
+		// An interrupted run can leave an incomplete last line
+		logger.Warningf("Stopped reading perf lines of %s after %d lines due to %v", maptestsFile, len(result), err)
+	}
 	return result
 }
 
@@ -179,6 +290,14 @@ func (mp *MapPerfTestResult) fill(report *DataFileReport) {
 }
 
 func (mp *MapPerfTestResult) init() {
+	mp.phases = phaseTimings{}
+	mp.latencies.reset()
+	mp.skews.reset()
+	mp.targetDuration = 0
+	mp.stopRequested = 0
+	mp.readOpsDone = 0
+	mp.writeOpsDone = 0
+	mp.mixedOpsDone = 0
 	mp.stopWatch.init()
 
 	mp.errorsKeyNotFound = 0
@@ -200,6 +319,10 @@ func (mp *MapPerfTestResult) setNbLines(nbLines int) {
 
 func (mp *MapPerfTestResult) stop() {
 	mp.stopWatch.stop()
+	mp.checkSize()
+}
+
+func (mp *MapPerfTestResult) checkSize() {
 	if mp.nbMapEntries != mp.nbExpectedMapEntries {
 		logger.Errorf("Size %d != %d\n", mp.nbMapEntries, mp.nbExpectedMapEntries)
 		mp.errorsSizeNotMatch++
@@ -222,8 +345,11 @@ func (mp *MapPerfTestResult) display(name string) {
 			mp.errorsValuesEqual, mp.errorsValuesNotEqual, mp.errorsPointerValuesNotEqual,
 			mp.errorsSizeNotMatch)
 	}
-	fmt.Printf("%s - %d: Took %v with %s error(s) and %d MB alloc\n",
-		name, mp.nbMapEntries, mp.execDuration(), q, mp.memDiff().TotalAlloc/(1024*1024))
+	fmt.Printf("%s - %d: Took %v with %s error(s), %d MB alloc, %v and %.1f bytes per entry\n",
+		name, mp.nbMapEntries, mp.execDuration(), q, mp.memDiff().TotalAlloc/(1024*1024),
+		&mp.heap, mp.heap.bytesPerEntry(mp.nbMapEntries))
+	fmt.Printf("%s - map created in %v, %v\n", name, mp.setupDuration, &mp.skews)
+	mp.latencies.display()
 }
 
 func (mp *MapPerfTestResult) NbErrors() int {
@@ -0,0 +1,630 @@
+package maptester
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/logger"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Data file formats:
+// v1: Sequence of records, each prefixed by a single length byte. No header.
+// v2: Magic, version byte, varint size prefixed DataFileHeader, then blocks of
+// NbLinesPerThreads records. Each block is a varint payload length, the payload
+// made of varint size prefixed records, and the big endian CRC-32C of the payload.
+// The v2 magic cannot start a v1 file, since the second byte of a v1 file is
+// always the IntTestLine key tag.
+// Compression: v2 blocks payload can be gzip compressed as declared in the header,
+// the CRC being of the compressed bytes. Any data file can also be fully gzip
+// compressed with the .gz suffix, but then cannot be read in parallel.
+const (
+	DataFileMagic      = "MAPT"
+	DataFileVersion1   = 1
+	DataFileVersion2   = 2
+	DataFileBufferSize = 1024 * 1024
+	MaxDataBlockSize   = 1024 * 1024 * 1024
+	GzipCompression    = "gzip"
+	GzipFileSuffix     = ".gz"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// dataFileWriter streams the lines of a data set to disk in v2 format.
+// Only the current block and the per thread offsets are kept in memory.
+type dataFileWriter struct {
+	filename          string
+	file              *os.File
+	out               *bufio.Writer
+	nbLines           int
+	currentPos        int64
+	block             bytes.Buffer
+	varintBuf         [binary.MaxVarintLen64]byte
+	offsetsPerThreads []int64
+	valueSizes        valueSizeStats
+	compressed        bytes.Buffer
+	gzipWriter        *gzip.Writer
+	rawSize           int64
+}
+
+func newDataFileWriter(dataFilename string, header *DataFileHeader) *dataFileWriter {
+	dataFile, err := os.OpenFile(dataFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0665)
+	if err != nil {
+		logger.Fatalf("Cannot open data file %s due to %v", dataFilename, err)
+	}
+	w := new(dataFileWriter)
+	w.filename = dataFilename
+	w.file = dataFile
+	w.out = bufio.NewWriterSize(dataFile, DataFileBufferSize)
+	w.offsetsPerThreads = make([]int64, 0, MaxConThreads)
+	w.valueSizes.distribution = header.ValueSizeDistribution
+
+	header.Version = DataFileVersion2
+	header.NbLinesPerBlock = NbLinesPerThreads
+	if header.Compression == GzipCompression {
+		w.gzipWriter = gzip.NewWriter(&w.compressed)
+	} else if header.Compression != "" {
+		logger.Fatalf("Compression %q not supported for data file %s", header.Compression, dataFilename)
+	}
+	data, err := proto.Marshal(header)
+	if err != nil {
+		logger.Fatalf("Failed to marshall header %v due to %v", header, err)
+	}
+	w.write([]byte(DataFileMagic))
+	w.write([]byte{DataFileVersion2})
+	w.writeUvarint(uint64(len(data)))
+	w.write(data)
+	w.rawSize = w.currentPos
+	return w
+}
+
+func (w *dataFileWriter) write(data []byte) {
+	n, err := w.out.Write(data)
+	if err != nil {
+		logger.Fatalf("Failed to write data file %s due to %v", w.filename, err)
+	}
+	w.currentPos += int64(n)
+}
+
+func (w *dataFileWriter) writeUvarint(v uint64) {
+	n := binary.PutUvarint(w.varintBuf[:], v)
+	w.write(w.varintBuf[:n])
+}
+
+func (w *dataFileWriter) writeLine(key Int3Key, value *TestValue) {
+	line := IntTestLine{Key: key[:], Value: value}
+	data, err := proto.Marshal(&line)
+	if err != nil {
+		logger.Fatalf("Failed to marshall %v due to %v", line, err)
+	}
+	n := binary.PutUvarint(w.varintBuf[:], uint64(len(data)))
+	w.block.Write(w.varintBuf[:n])
+	w.block.Write(data)
+	w.valueSizes.add(len(value.GetSVal()))
+	w.nbLines++
+	if w.nbLines%NbLinesPerThreads == 0 {
+		w.flushBlock()
+	}
+}
+
+func (w *dataFileWriter) flushBlock() {
+	if w.block.Len() == 0 {
+		return
+	}
+	w.offsetsPerThreads = append(w.offsetsPerThreads, w.currentPos)
+	payload := w.block.Bytes()
+	blockStart := w.currentPos
+	if w.gzipWriter != nil {
+		w.compressed.Reset()
+		w.gzipWriter.Reset(&w.compressed)
+		_, err := w.gzipWriter.Write(payload)
+		utils.ExitOnError(err)
+		utils.ExitOnError(w.gzipWriter.Close())
+		w.rawSize += int64(len(payload)) + 4
+		n := binary.PutUvarint(w.varintBuf[:], uint64(len(payload)))
+		w.rawSize += int64(n)
+		payload = w.compressed.Bytes()
+	}
+	w.writeUvarint(uint64(len(payload)))
+	w.write(payload)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Checksum(payload, crcTable))
+	w.write(crc)
+	w.block.Reset()
+	if w.gzipWriter == nil {
+		w.rawSize += w.currentPos - blockStart
+	}
+}
+
+// close flushes the data file and returns the offsets of each thread segment
+func (w *dataFileWriter) close() []int64 {
+	w.flushBlock()
+	utils.ExitOnError(w.out.Flush())
+	utils.CloseFile(w.file)
+	fmt.Println(w.filename, "value sizes", w.valueSizes.String())
+	if w.gzipWriter != nil {
+		fmt.Printf("%s compressed %d MB to %d MB, ratio %.2f\n", w.filename,
+			w.rawSize/(1024*1024), w.currentPos/(1024*1024), w.compressionRatio())
+	}
+	return w.offsetsPerThreads
+}
+
+func (w *dataFileWriter) compressionRatio() float64 {
+	return float64(w.rawSize) / float64(w.currentPos)
+}
+
+// fillReport adds the file sizes, compression and value sizes of the closed data file to the report
+func (w *dataFileWriter) fillReport(report *DataFileReport) {
+	report.FileSize = w.currentPos
+	report.RawSize = w.rawSize
+	if w.gzipWriter != nil {
+		report.Compression = GzipCompression
+	}
+	w.valueSizes.fill(report)
+}
+
+// valueSizeStats aggregates the sizes of the values strings of a data set
+type valueSizeStats struct {
+	distribution string
+	nbValues     int
+	min          int
+	max          int
+	total        int64
+}
+
+func (vs *valueSizeStats) add(size int) {
+	if vs.nbValues == 0 || size < vs.min {
+		vs.min = size
+	}
+	if size > vs.max {
+		vs.max = size
+	}
+	vs.nbValues++
+	vs.total += int64(size)
+}
+
+func (vs *valueSizeStats) fill(report *DataFileReport) {
+	report.ValueSizeDistribution = vs.distribution
+	report.MinValueSize = int32(vs.min)
+	report.MaxValueSize = int32(vs.max)
+	report.TotalValueSize = vs.total
+}
+
+func (vs *valueSizeStats) String() string {
+	avg := 0.0
+	if vs.nbValues > 0 {
+		avg = float64(vs.total) / float64(vs.nbValues)
+	}
+	return fmt.Sprintf("%s min=%d max=%d avg=%.1f", vs.distribution, vs.min, vs.max, avg)
+}
+
+// dataFileReader reads lines of a v1 or v2 data file, checking the blocks CRC of v2
+type dataFileReader struct {
+	filename string
+	file     *os.File
+	in       *bufio.Reader
+	version  int
+	header   *DataFileHeader
+	pos      int64
+	block    []byte
+	blockPos int
+	// Whole file gzip compression prevents seeking
+	gzipFile      bool
+	gzipReader    *gzip.Reader
+	blockInflater *gzip.Reader
+	rawBytes      int64
+	inflating     time.Duration
+}
+
+// dataReadStats are the sizes and decompression time of reading a data file
+type dataReadStats struct {
+	fileBytes int64
+	rawBytes  int64
+	inflating time.Duration
+}
+
+func (stats *dataReadStats) add(r *dataFileReader) {
+	stats.rawBytes += r.rawBytes
+	stats.inflating += r.inflating
+}
+
+func (stats *dataReadStats) display(name string, readDuration time.Duration) {
+	mb := float64(1024 * 1024)
+	fmt.Printf("%s - read %.1f MB file with %.1f MB raw data at %.1f MB/s, decompression took %v\n",
+		name, float64(stats.fileBytes)/mb, float64(stats.rawBytes)/mb,
+		float64(stats.rawBytes)/mb/readDuration.Seconds(), stats.inflating)
+}
+
+// timedReader accumulates the time spent reading
+type timedReader struct {
+	in       io.Reader
+	duration *time.Duration
+}
+
+func (tr *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := tr.in.Read(p)
+	*tr.duration += time.Since(start)
+	return n, err
+}
+
+func openDataFile(dataFilename string) (*dataFileReader, error) {
+	return openDataFileSize(dataFilename, DataFileBufferSize)
+}
+
+func openDataFileSize(dataFilename string, bufferSize int) (*dataFileReader, error) {
+	dataFile, err := os.Open(dataFilename)
+	if err != nil {
+		return nil, err
+	}
+	r := new(dataFileReader)
+	r.filename = dataFilename
+	r.file = dataFile
+	if strings.HasSuffix(dataFilename, GzipFileSuffix) {
+		r.gzipFile = true
+		r.gzipReader, err = gzip.NewReader(dataFile)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.in = bufio.NewReaderSize(&timedReader{r.gzipReader, &r.inflating}, bufferSize)
+	} else {
+		r.in = bufio.NewReaderSize(dataFile, bufferSize)
+	}
+	err = r.readHeader()
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// seek moves the reader to the offset of a thread segment
+func (r *dataFileReader) seek(offset int64) error {
+	if r.gzipFile {
+		return fmt.Errorf("cannot seek in compressed data file %s", r.filename)
+	}
+	_, err := r.file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	r.in.Reset(r.file)
+	r.pos = offset
+	r.block = nil
+	r.blockPos = 0
+	return nil
+}
+
+func (r *dataFileReader) readHeader() error {
+	magic, err := r.in.Peek(len(DataFileMagic) + 1)
+	if len(magic) <= len(DataFileMagic) || string(magic[:len(DataFileMagic)]) != DataFileMagic {
+		if err != nil && err != io.EOF {
+			return err
+		}
+		// No header in v1
+		r.version = DataFileVersion1
+		return nil
+	}
+	r.version = int(magic[len(DataFileMagic)])
+	if r.version != DataFileVersion2 {
+		return fmt.Errorf("data file %s has unsupported version %d", r.filename, r.version)
+	}
+	r.skip(len(magic))
+	data, err := r.readPrefixed(MaxDataBlockSize)
+	if err != nil {
+		return fmt.Errorf("cannot read header of data file %s due to %v", r.filename, err)
+	}
+	r.header = new(DataFileHeader)
+	err = proto.Unmarshal(data, r.header)
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal header of data file %s due to %v", r.filename, err)
+	}
+	if r.header.Compression != "" && r.header.Compression != GzipCompression {
+		return fmt.Errorf("data file %s has unsupported compression %q", r.filename, r.header.Compression)
+	}
+	return nil
+}
+
+func (r *dataFileReader) skip(n int) {
+	_, _ = r.in.Discard(n)
+	r.pos += int64(n)
+}
+
+func (r *dataFileReader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r.in)
+	if err == nil {
+		var buf [binary.MaxVarintLen64]byte
+		r.pos += int64(binary.PutUvarint(buf[:], v))
+	}
+	return v, err
+}
+
+func (r *dataFileReader) readPrefixed(maxSize uint64) ([]byte, error) {
+	length, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length > maxSize {
+		return nil, fmt.Errorf("block size %d too big at offset %d", length, r.pos)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r.in, data)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	r.pos += int64(length)
+	return data, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *dataFileReader) readBlock() error {
+	offset := r.pos
+	payload, err := r.readPrefixed(MaxDataBlockSize)
+	if err != nil {
+		return err
+	}
+	crc := make([]byte, 4)
+	_, err = io.ReadFull(r.in, crc)
+	if err != nil {
+		return fmt.Errorf("cannot read CRC of block at offset %d due to %v", offset, unexpectedEOF(err))
+	}
+	r.pos += 4
+	if binary.BigEndian.Uint32(crc) != crc32.Checksum(payload, crcTable) {
+		return fmt.Errorf("corrupted block at offset %d: CRC mismatch", offset)
+	}
+	if r.header.Compression == GzipCompression {
+		payload, err = r.inflate(payload)
+		if err != nil {
+			return fmt.Errorf("cannot decompress block at offset %d due to %v", offset, err)
+		}
+	}
+	r.rawBytes += int64(len(payload))
+	r.block = payload
+	r.blockPos = 0
+	return nil
+}
+
+func (r *dataFileReader) inflate(compressed []byte) ([]byte, error) {
+	start := time.Now()
+	defer func() { r.inflating += time.Since(start) }()
+	var err error
+	if r.blockInflater == nil {
+		r.blockInflater, err = gzip.NewReader(bytes.NewReader(compressed))
+	} else {
+		err = r.blockInflater.Reset(bytes.NewReader(compressed))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r.blockInflater)
+}
+
+// nextRecord returns the next line record bytes, or nil at the end of the file
+func (r *dataFileReader) nextRecord() ([]byte, error) {
+	if r.version == DataFileVersion1 {
+		length, err := r.in.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		_, err = io.ReadFull(r.in, data)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		r.pos += int64(length) + 1
+		r.rawBytes += int64(length) + 1
+		return data, nil
+	}
+	if r.blockPos >= len(r.block) {
+		err := r.readBlock()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	length, n := binary.Uvarint(r.block[r.blockPos:])
+	if n <= 0 || uint64(len(r.block)-r.blockPos-n) < length {
+		return nil, fmt.Errorf("invalid record length in block before offset %d", r.pos)
+	}
+	start := r.blockPos + n
+	r.blockPos = start + int(length)
+	return r.block[start:r.blockPos], nil
+}
+
+// nextLine unmarshal the next line, returning false at the end of the file
+func (r *dataFileReader) nextLine(line *IntTestLine) (bool, error) {
+	data, err := r.nextRecord()
+	if err != nil || data == nil {
+		return false, err
+	}
+	err = proto.Unmarshal(data, line)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *dataFileReader) close() {
+	utils.CloseFile(r.file)
+}
+
+// findDataFilename returns the existing data file, compressed or not
+func findDataFilename(name string, size int) string {
+	dataFilename := getDataFilename(name, size)
+	if !utils.FileExists(dataFilename) && utils.FileExists(dataFilename+GzipFileSuffix) {
+		return dataFilename + GzipFileSuffix
+	}
+	return dataFilename
+}
+
+// readDataSegments fills the data set from the data file. When the report
+// offsets match the thread segments, the segments are decoded by a pool of GOMAXPROCS
+// goroutines, each with its own reader, directly in place in the data set slices.
+func readDataSegments(dataFilename string, im *IntMapTestDataSet, report *DataFileReport) (*dataReadStats, error) {
+	stats := new(dataReadStats)
+	fileInfo, err := os.Stat(dataFilename)
+	if err != nil {
+		return stats, err
+	}
+	stats.fileBytes = fileInfo.Size()
+	dataReader, err := openDataFile(dataFilename)
+	if err != nil {
+		return stats, err
+	}
+	defer dataReader.close()
+	linesPerSegment := NbLinesPerThreads
+	if dataReader.header != nil {
+		if dataReader.header.NbLines != report.NbLines {
+			return stats, fmt.Errorf("header has %d lines but report has %d", dataReader.header.NbLines, report.NbLines)
+		}
+		linesPerSegment = int(dataReader.header.NbLinesPerBlock)
+	}
+	offsets := report.OffsetsPerThreads
+	if dataReader.gzipFile {
+		err = readDataLines(dataReader, im, 0, im.size, -1)
+		stats.add(dataReader)
+		return stats, err
+	}
+	if linesPerSegment <= 0 || len(offsets) != (im.size+linesPerSegment-1)/linesPerSegment {
+		logger.Warningf("Data file %s has %d offsets not matching %d lines. Reading sequentially.",
+			dataFilename, len(offsets), im.size)
+		err = readDataLines(dataReader, im, 0, im.size, -1)
+		stats.add(dataReader)
+		return stats, err
+	}
+
+	nbWorkers := runtime.GOMAXPROCS(0)
+	if nbWorkers > len(offsets) {
+		nbWorkers = len(offsets)
+	}
+	segments := make(chan int, len(offsets))
+	for s := range offsets {
+		segments <- s
+	}
+	close(segments)
+	statsMutex := new(sync.Mutex)
+	errs := make([]error, len(offsets))
+	wg := new(sync.WaitGroup)
+	for w := 0; w < nbWorkers; w++ {
+		segmentReader, err := openDataFileSize(dataFilename, 64*1024)
+		if err != nil {
+			wg.Wait()
+			return stats, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer segmentReader.close()
+			for s := range segments {
+				start := s * linesPerSegment
+				end := start + linesPerSegment
+				if end > im.size {
+					end = im.size
+				}
+				nextOffset := int64(-1)
+				if s+1 < len(offsets) {
+					nextOffset = offsets[s+1]
+				}
+				err := segmentReader.seek(offsets[s])
+				if err == nil {
+					err = readDataLines(segmentReader, im, start, end, nextOffset)
+				}
+				if err != nil {
+					errs[s] = fmt.Errorf("segment %d at offset %d: %v", s, offsets[s], err)
+				}
+			}
+			statsMutex.Lock()
+			stats.add(segmentReader)
+			statsMutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// readDataLines decodes the lines start to end from the reader current position,
+// and checks the reader ends exactly at the next offset, or at the end of file if negative.
+func readDataLines(r *dataFileReader, im *IntMapTestDataSet, start, end int, nextOffset int64) error {
+	imLine := new(IntTestLine)
+	for i := start; i < end; i++ {
+		found, err := r.nextLine(imLine)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", i, err)
+		}
+		if !found {
+			return fmt.Errorf("got end of file too early at line %d", i)
+		}
+		if len(imLine.GetKey()) != 3 {
+			return fmt.Errorf("line %d has key %v", i, imLine.GetKey())
+		}
+		for k := 0; k < 3; k++ {
+			im.keys[i][k] = imLine.GetKey()[k]
+		}
+		im.values[i] = *imLine.GetValue()
+	}
+	if nextOffset >= 0 && (r.pos != nextOffset || r.blockPos != len(r.block)) {
+		return fmt.Errorf("decoded %d lines ending at offset %d instead of %d", end-start, r.pos, nextOffset)
+	}
+	if nextOffset < 0 {
+		found, err := r.nextLine(imLine)
+		if err != nil || found {
+			return fmt.Errorf("data after the last line %d at offset %d: %v", end-1, r.pos, err)
+		}
+	}
+	return nil
+}
+
+// newDataFileReport creates the report of a data set from the histogram of
+// the number of lines sharing the same key.
+func newDataFileReport(nbLines int, sameKeysCount map[int]int32, offsetsPerThreads []int64) *DataFileReport {
+	max := 0
+	nbEntries := int32(0)
+	for k, v := range sameKeysCount {
+		nbEntries += v
+		if k > max {
+			max = k
+		}
+	}
+	report := new(DataFileReport)
+	report.NbLines = int32(nbLines)
+	report.NbEntries = nbEntries
+	report.NbSameKeys = report.NbLines - report.NbEntries
+	if max > 1 {
+		report.NbOfTimesSameKey = make([]int32, max-1)
+		for k, v := range sameKeysCount {
+			if k > 1 {
+				report.NbOfTimesSameKey[k-2] = v
+			}
+		}
+	}
+	report.OffsetsPerThreads = make([]int64, len(offsetsPerThreads))
+	copy(report.OffsetsPerThreads, offsetsPerThreads)
+	return report
+}
@@ -0,0 +1,191 @@
+package maptester
+
+import (
+	"compress/gzip"
+	"github.com/freddy33/maptester/utils"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestDataFile(t *testing.T, filename string, size int, valueSize int) *intDataGenerator {
+	gen := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(valueSize), 12)
+	writer := newDataFileWriter(filename, &DataFileHeader{Seed: 12, KeyType: "int3d", NbLines: int32(size)})
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		writer.writeLine(key, &value)
+	}
+	offsets := writer.close()
+	assert.Equal(t, (size+NbLinesPerThreads-1)/NbLinesPerThreads, len(offsets))
+	return gen
+}
+
+func readAllTestLines(filename string) ([]*IntTestLine, *dataFileReader, error) {
+	reader, err := openDataFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.close()
+	lines := make([]*IntTestLine, 0)
+	for {
+		line := new(IntTestLine)
+		found, err := reader.nextLine(line)
+		if err != nil {
+			return lines, reader, err
+		}
+		if !found {
+			return lines, reader, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestDataFileV2BigValues(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v2.data")
+	size := NbLinesPerThreads + 100
+	gen := writeTestDataFile(t, filename, size, 300)
+
+	lines, reader, err := readAllTestLines(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, DataFileVersion2, reader.version)
+	assert.Equal(t, int64(12), reader.header.Seed)
+	assert.Equal(t, int32(size), reader.header.NbLines)
+	assert.Equal(t, int32(NbLinesPerThreads), reader.header.NbLinesPerBlock)
+	assert.Equal(t, size, len(lines))
+	for i, line := range lines {
+		_, key, value := gen.line(i)
+		assert.Equal(t, key[:], line.Key)
+		assert.Equal(t, value.SVal, line.Value.SVal)
+		assert.Equal(t, 300, len(line.Value.SVal))
+	}
+}
+
+func TestDataFileV2Corrupted(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "corrupted.data")
+	size := 2 * NbLinesPerThreads
+	writeTestDataFile(t, filename, size, 12)
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	data[len(data)-100] ^= 0x01
+	assert.NoError(t, ioutil.WriteFile(filename, data, 0644))
+
+	lines, _, err := readAllTestLines(filename)
+	assert.Error(t, err)
+	assert.Equal(t, NbLinesPerThreads, len(lines))
+
+	assert.NoError(t, ioutil.WriteFile(filename, data[:len(data)/4], 0644))
+	lines, _, err = readAllTestLines(filename)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(lines))
+}
+
+func TestDataFileV1(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v1.data")
+	file, err := os.Create(filename)
+	assert.NoError(t, err)
+	size := 1000
+	gen := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(12), 34)
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		data, err := proto.Marshal(&IntTestLine{Key: key[:], Value: &value})
+		assert.NoError(t, err)
+		utils.WriteDataBlockPrefixSize(file, data)
+	}
+	utils.CloseFile(file)
+
+	lines, reader, err := readAllTestLines(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, DataFileVersion1, reader.version)
+	assert.Nil(t, reader.header)
+	assert.Equal(t, size, len(lines))
+	for i, line := range lines {
+		_, key, _ := gen.line(i)
+		assert.Equal(t, key[:], line.Key)
+		assert.Equal(t, int64(i), line.Value.Idx)
+	}
+}
+
+func TestReadDataSegments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "segments.data")
+	size := 3*NbLinesPerThreads + 7
+	gen := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(12), 56)
+	writer := newDataFileWriter(filename, &DataFileHeader{NbLines: int32(size)})
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		writer.writeLine(key, &value)
+	}
+	report := newDataFileReport(size, map[int]int32{1: int32(size)}, writer.close())
+	assert.Equal(t, 4, len(report.OffsetsPerThreads))
+
+	im := &IntMapTestDataSet{size, make([]Int3Key, size), make([]TestValue, size), nil}
+	_, err := readDataSegments(filename, im, report)
+	assert.NoError(t, err)
+	assertTestDataSet(t, gen, im)
+
+	report.OffsetsPerThreads[2]++
+	_, err = readDataSegments(filename, im, report)
+	assert.Error(t, err)
+	report.OffsetsPerThreads[2]--
+
+	// The last segment has to end the file
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, append(data, 1, 2, 3), 0644))
+	_, err = readDataSegments(filename, im, report)
+	assert.Error(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, data[:len(data)-3], 0644))
+	_, err = readDataSegments(filename, im, report)
+	assert.Error(t, err)
+}
+
+func assertTestDataSet(t *testing.T, gen *intDataGenerator, im *IntMapTestDataSet) {
+	for i := 0; i < im.size; i++ {
+		_, key, value := gen.line(i)
+		assert.Equal(t, key, im.keys[i])
+		assert.Equal(t, value.SVal, im.values[i].SVal)
+		assert.Equal(t, int64(i), im.values[i].Idx)
+	}
+}
+
+func TestCompressedDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "compressed.data")
+	size := 2*NbLinesPerThreads + 3
+	gen := newIntDataGenerator(size, 0.25, FixedValueSizeDistribution(40), 78)
+	writer := newDataFileWriter(filename, &DataFileHeader{NbLines: int32(size), Compression: GzipCompression})
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		writer.writeLine(key, &value)
+	}
+	report := newDataFileReport(size, map[int]int32{1: int32(size)}, writer.close())
+	writer.fillReport(report)
+	assert.Equal(t, GzipCompression, report.Compression)
+	assert.True(t, report.RawSize > report.FileSize, "raw %d file %d", report.RawSize, report.FileSize)
+
+	im := &IntMapTestDataSet{size, make([]Int3Key, size), make([]TestValue, size), nil}
+	stats, err := readDataSegments(filename, im, report)
+	assert.NoError(t, err)
+	assert.Equal(t, report.FileSize, stats.fileBytes)
+	assert.True(t, stats.rawBytes > stats.fileBytes)
+	assertTestDataSet(t, gen, im)
+
+	// Full file gzip of the compressed data file
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	gzFile, err := os.Create(filename + GzipFileSuffix)
+	assert.NoError(t, err)
+	gzWriter := gzip.NewWriter(gzFile)
+	_, err = gzWriter.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gzWriter.Close())
+	utils.CloseFile(gzFile)
+
+	im = &IntMapTestDataSet{size, make([]Int3Key, size), make([]TestValue, size), nil}
+	_, err = readDataSegments(filename+GzipFileSuffix, im, report)
+	assert.NoError(t, err)
+	assertTestDataSet(t, gen, im)
+}
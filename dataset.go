@@ -7,6 +7,8 @@ type IntMapTestDataSet struct {
 	size   int
 	keys   []Int3Key
 	values []TestValue
+	// The memory mapped flat file the keys and the value strings point to
+	mapped []byte
 }
 
 func (im *IntMapTestDataSet) getKey(i int) Int3Key {
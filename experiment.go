@@ -0,0 +1,325 @@
+package maptester
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/google/logger"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ExperimentConfig describes an experiment matrix in a JSON file. The dimensions not set
+// keep their default values, constraints like "r/w threads ratio>=1" filter the run configurations.
+type ExperimentConfig struct {
+	Name            string    `json:"name"`
+	ConflictRatios  []float32 `json:"conflictRatios"`
+	ValueSizes      []string  `json:"valueSizes"`
+	DataSizes       []string  `json:"dataSizes"`
+	InitRatios      []float32 `json:"initRatios"`
+	NbReadThreads   []int     `json:"nbReadThreads"`
+	NbWriteThreads  []int     `json:"nbWriteThreads"`
+	PercentMiss     []float32 `json:"percentMiss"`
+	ReadWriteRatios []int     `json:"readWriteRatios"`
+	WorkloadMixes   []string  `json:"workloadMixes"`
+	Scenarios       []string  `json:"scenarios"`
+	GoMaxProcs      []int     `json:"goMaxProcs"`
+	GCPercents      []int     `json:"gcPercents"`
+	MemoryLimits    []string  `json:"memoryLimits"`
+	Constraints     []string  `json:"constraints"`
+	MapTypes        []string  `json:"mapTypes"`
+	RatioToRun      *float32  `json:"ratioToRun"`
+}
+
+// The content of the experiment config file, saved with the results
+var ExperimentConfigContent []byte
+
+// The run configurations not matching all constraints are not built
+var Constraints []*Condition
+
+// The map types to test, all if empty
+var SelectedMapTypes []string
+
+// Condition compares a dimension of a run configuration to a value, like "percent miss<=0.25"
+type Condition struct {
+	dimension string
+	op        string
+	value     string
+}
+
+// Operators with 2 characters first, so they are found before their prefix
+var conditionOperators = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+// The dimensions usable in conditions, in addition to Dimensions
+var conditionExtraDimensions = []string{"nb read threads", "nb write threads"}
+
+func ParseCondition(s string) (*Condition, error) {
+	for _, op := range conditionOperators {
+		idx := strings.Index(s, op)
+		if idx < 0 {
+			continue
+		}
+		c := &Condition{
+			dimension: strings.TrimSpace(s[:idx]),
+			op:        op,
+			value:     strings.TrimSpace(s[idx+len(op):]),
+		}
+		if !containsString(Dimensions, c.dimension) && !containsString(conditionExtraDimensions, c.dimension) {
+			return nil, fmt.Errorf("dimension %q of condition %q unknown, expected one of %s", c.dimension, s,
+				strings.Join(append(append([]string{}, Dimensions...), conditionExtraDimensions...), ", "))
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("condition %q has no operator, expected one of %s", s, strings.Join(conditionOperators, " "))
+}
+
+func (c *Condition) String() string {
+	return c.dimension + c.op + c.value
+}
+
+// dimensionValue returns the value of a dimension of the run configuration as written in the CSV
+func (rc *RunConfiguration) dimensionValue(dimension string) string {
+	float := func(f float32) string {
+		return strconv.FormatFloat(float64(f), 'g', -1, 32)
+	}
+	switch dimension {
+	case "key type":
+		return rc.dataConf.keyType
+	case "init ratio":
+		return float(rc.testConf.initRatio)
+	case "conflict ratio":
+		return float(rc.dataConf.conflictRatio)
+	case "r/w threads ratio":
+		return float(rc.readWriteThreadRatio)
+	case "percent miss":
+		return float(rc.testConf.percentMiss)
+	case "r/w nb ratio":
+		return strconv.Itoa(rc.readWriteNbRatio)
+	case "value size":
+		return rc.dataConf.valueSize.Name()
+	case "data size":
+		return strconv.Itoa(rc.dataConf.size)
+	case "workload mix":
+		return rc.mix.Name()
+	case "scenario":
+		return rc.scenario
+	case "gomaxprocs":
+		return strconv.Itoa(rc.runtime.goMaxProcs)
+	case "gc percent":
+		return strconv.Itoa(rc.runtime.gcPercent)
+	case "memory limit":
+		return strconv.FormatInt(rc.runtime.memoryLimit, 10)
+	case "nb read threads":
+		return strconv.Itoa(rc.testConf.nbReadThreads)
+	case "nb write threads":
+		return strconv.Itoa(rc.testConf.nbWriteThreads)
+	}
+	logger.Fatalf("Dimension %q not supported", dimension)
+	return ""
+}
+
+// matches compares numerically if both values are numbers, as strings otherwise
+func (c *Condition) matches(rc *RunConfiguration) bool {
+	actual := rc.dimensionValue(c.dimension)
+	cmp := strings.Compare(actual, c.value)
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(c.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func matchesAll(conditions []*Condition, rc *RunConfiguration) bool {
+	for _, c := range conditions {
+		if !c.matches(rc) {
+			return false
+		}
+	}
+	return true
+}
+
+func isMapType(name string) bool {
+	for _, mt := range MapTypes {
+		if mt.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelected returns true if the map type is in the selected map types, or if there is no selection
+func (mt MapType) isSelected() bool {
+	return len(SelectedMapTypes) == 0 || containsString(SelectedMapTypes, mt.name)
+}
+
+// LoadExperimentConfig replaces the dimensions set in the config file and rebuilds all configurations
+func LoadExperimentConfig(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	config := new(ExperimentConfig)
+	err = decoder.Decode(config)
+	if err != nil {
+		return fmt.Errorf("cannot parse experiment config %s: %v", filename, err)
+	}
+	err = config.apply()
+	if err != nil {
+		return fmt.Errorf("invalid experiment config %s: %v", filename, err)
+	}
+	ExperimentConfigContent = content
+	BuildConfigurations()
+	return nil
+}
+
+func (config *ExperimentConfig) apply() error {
+	var err error
+	var dataSizes []int
+	if len(config.DataSizes) > 0 {
+		dataSizes, err = utils.ParseSizes(strings.Join(config.DataSizes, ","))
+		if err != nil {
+			return err
+		}
+	}
+	valueSizes := make([]ValueSizeDistribution, len(config.ValueSizes))
+	for i, name := range config.ValueSizes {
+		valueSizes[i], err = ParseValueSizeDistribution(name)
+		if err != nil {
+			return err
+		}
+	}
+	mixes := make([]*WorkloadMix, len(config.WorkloadMixes))
+	for i, name := range config.WorkloadMixes {
+		mixes[i], err = ParseWorkloadMix(name)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range config.Scenarios {
+		_, err = ParseScenario(name)
+		if err != nil {
+			return err
+		}
+	}
+	err = checkGoMaxProcs(config.GoMaxProcs)
+	if err != nil {
+		return err
+	}
+	var memoryLimits []int64
+	if len(config.MemoryLimits) > 0 {
+		memoryLimits, err = ParseMemoryLimits(strings.Join(config.MemoryLimits, ","))
+		if err != nil {
+			return err
+		}
+	}
+	constraints := make([]*Condition, len(config.Constraints))
+	for i, s := range config.Constraints {
+		constraints[i], err = ParseCondition(s)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range config.MapTypes {
+		if !isMapType(name) {
+			return fmt.Errorf("map type %q unknown", name)
+		}
+	}
+	for _, nbThreads := range append(append([]int{}, config.NbReadThreads...), config.NbWriteThreads...) {
+		if nbThreads < 1 || nbThreads > MaxConThreads {
+			return fmt.Errorf("number of threads %d not in [1,%d]", nbThreads, MaxConThreads)
+		}
+	}
+	if config.RatioToRun != nil && (*config.RatioToRun <= 0.0 || *config.RatioToRun > 1.0) {
+		return fmt.Errorf("ratio to run %f not in ]0,1]", *config.RatioToRun)
+	}
+
+	if len(config.ConflictRatios) > 0 {
+		ConflictRatioValues = config.ConflictRatios
+	}
+	if len(valueSizes) > 0 {
+		ValueSizes = valueSizes
+	}
+	if len(dataSizes) > 0 {
+		DataSizes = dataSizes
+	}
+	if len(config.InitRatios) > 0 {
+		InitRatioValues = config.InitRatios
+	}
+	if len(config.NbReadThreads) > 0 {
+		NbReadThreads = config.NbReadThreads
+	}
+	if len(config.NbWriteThreads) > 0 {
+		NbWriteThreads = config.NbWriteThreads
+	}
+	if len(config.PercentMiss) > 0 {
+		PercentMissValues = config.PercentMiss
+	}
+	if len(config.ReadWriteRatios) > 0 {
+		NbReadWriteRatio = config.ReadWriteRatios
+	}
+	if len(mixes) > 0 {
+		WorkloadMixes = mixes
+	}
+	if len(config.Scenarios) > 0 {
+		Scenarios = config.Scenarios
+	}
+	if len(config.GoMaxProcs) > 0 {
+		GoMaxProcsValues = config.GoMaxProcs
+	}
+	if len(config.GCPercents) > 0 {
+		GCPercentValues = config.GCPercents
+	}
+	if len(memoryLimits) > 0 {
+		MemoryLimitValues = memoryLimits
+	}
+	Constraints = constraints
+	if len(config.MapTypes) > 0 {
+		SelectedMapTypes = config.MapTypes
+	}
+	if config.RatioToRun != nil {
+		RatioToRun = *config.RatioToRun
+	}
+	return nil
+}
+
+func getExperimentConfigFilename(resultFilename string) string {
+	return strings.TrimSuffix(resultFilename, ".csv") + "-config.json"
+}
+
+// saveExperimentConfig writes the experiment config next to the results file
+func saveExperimentConfig(resultFilename string) {
+	if ExperimentConfigContent == nil {
+		return
+	}
+	filename := getExperimentConfigFilename(resultFilename)
+	err := ioutil.WriteFile(filename, ExperimentConfigContent, 0644)
+	if err != nil {
+		logger.Errorf("Cannot save experiment config in %s due to %v", filename, err)
+	}
+}
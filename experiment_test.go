@@ -0,0 +1,75 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	rc := newTestRunConfiguration(1000, 4, 16, 10)
+	rc.readWriteThreadRatio = 4.0
+	for s, expected := range map[string]bool{
+		"percent miss=0.25":       true,
+		"percent miss!=0.25":      false,
+		"r/w threads ratio>=1":    true,
+		"r/w threads ratio<1":     false,
+		"nb read threads>4":       true,
+		"nb write threads<=2":     false,
+		"data size=1000":          true,
+		"scenario=interleaved":    true,
+		"workload mix = populate": true,
+		"value size=v16":          false,
+	} {
+		c, err := ParseCondition(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, c.matches(rc), s)
+	}
+	_, err := ParseCondition("percent=0.5")
+	assert.Error(t, err)
+	_, err = ParseCondition("percent miss")
+	assert.Error(t, err)
+}
+
+func TestLoadExperimentConfig(t *testing.T) {
+	savedConflicts, savedSizes, savedReads, savedWrites := ConflictRatioValues, DataSizes, NbReadThreads, NbWriteThreads
+	savedInits, savedMisses, savedRatios, savedRatioToRun := InitRatioValues, PercentMissValues, NbReadWriteRatio, RatioToRun
+	defer func() {
+		ConflictRatioValues, DataSizes, NbReadThreads, NbWriteThreads = savedConflicts, savedSizes, savedReads, savedWrites
+		InitRatioValues, PercentMissValues, NbReadWriteRatio, RatioToRun = savedInits, savedMisses, savedRatios, savedRatioToRun
+		Constraints, SelectedMapTypes, ExperimentConfigContent = nil, nil, nil
+		BuildConfigurations()
+	}()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "exp.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"name": "threads", "conflictRatios": [0.25], "dataSizes": ["10K"],
+		"initRatios": [0.5], "nbReadThreads": [1, 4, 16], "nbWriteThreads": [1, 4], "percentMiss": [0.0],
+		"readWriteRatios": [8], "constraints": ["r/w threads ratio>=1"], "mapTypes": ["RWMutex", "syncMap"],
+		"ratioToRun": 1.0}`), 0644))
+	assert.NoError(t, LoadExperimentConfig(filename))
+	assert.Equal(t, []int{10000}, DataSizes)
+	nbInt3d := 0
+	for _, rc := range RunConfigurations {
+		assert.True(t, rc.readWriteThreadRatio >= 1.0, rc.GetRunName())
+		if rc.dataConf.keyType == KeyTypes[0] {
+			nbInt3d++
+		}
+	}
+	// 5 thread pairs and 2 scenarios
+	assert.Equal(t, 10, nbInt3d)
+	assert.Equal(t, 20, len(getAllRunnableTests()))
+
+	resultFilename := filepath.Join(dir, "results.csv")
+	saveExperimentConfig(resultFilename)
+	saved, err := ioutil.ReadFile(filepath.Join(dir, "results-config.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, ExperimentConfigContent, saved)
+
+	for _, invalid := range []string{`{"unknown": 1}`, `{"mapTypes": ["hashMap"]}`, `{"constraints": ["threads=1"]}`,
+		`{"nbReadThreads": [0]}`, `{"ratioToRun": 2.0}`, `{"workloadMixes": ["ycsbZ"]}`} {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(invalid), 0644))
+		assert.Error(t, LoadExperimentConfig(filename), invalid)
+	}
+}
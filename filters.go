@@ -0,0 +1,64 @@
+package maptester
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RunFilter selects the run configurations with a matching run name, all if nil
+var RunFilter *regexp.Regexp
+
+// WhereConditions select the run configurations matching all of them
+var WhereConditions []*Condition
+
+// DryRun lists the selected tests and their estimated time without running them
+var DryRun = false
+
+// SelectRuns selects the run configurations whose whole run name matches the regular expression
+func SelectRuns(expr string) error {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid run filter %q: %v", expr, err)
+	}
+	RunFilter = re
+	return nil
+}
+
+// SelectMapTypes selects the map types to test
+func SelectMapTypes(names []string) error {
+	for _, name := range names {
+		if !isMapType(name) {
+			return fmt.Errorf("map type %q unknown", name)
+		}
+	}
+	SelectedMapTypes = names
+	return nil
+}
+
+// AddWhereConditions adds comma separated conditions like "percent miss=0.5,nb read threads>=4"
+func AddWhereConditions(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		c, err := ParseCondition(part)
+		if err != nil {
+			return err
+		}
+		WhereConditions = append(WhereConditions, c)
+	}
+	return nil
+}
+
+// isSelected returns true if the run configuration passes the run filter and the where conditions
+func (rc *RunConfiguration) isSelected() bool {
+	if RunFilter != nil && !RunFilter.MatchString(rc.GetRunName()) {
+		return false
+	}
+	return matchesAll(WhereConditions, rc)
+}
+
+func displayDryRun(perfTests []*MapPerfTestResult) {
+	for idx, perfTest := range perfTests {
+		fmt.Println(idx, ":", perfTest.Name())
+	}
+	fmt.Printf("Selected %d tests which means %.1f minutes\n", len(perfTests), estimatedSeconds(perfTests)/60.0)
+}
@@ -0,0 +1,38 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRunSelectionFilters(t *testing.T) {
+	savedRatioToRun := RatioToRun
+	defer func() {
+		RatioToRun = savedRatioToRun
+		RunFilter, WhereConditions, SelectedMapTypes = nil, nil, nil
+	}()
+	RatioToRun = 1.0
+
+	assert.NoError(t, SelectMapTypes([]string{"fredMap", "syncMap"}))
+	assert.NoError(t, SelectRuns("int3d-c50-.*-wt32-.*"))
+	assert.NoError(t, AddWhereConditions("percent miss=0.5,nb read threads>=4"))
+	perfTests := getAllRunnableTests()
+	assert.True(t, len(perfTests) > 0)
+	for _, perfTest := range perfTests {
+		name := perfTest.Name()
+		assert.True(t, strings.HasPrefix(name, "int3d-c50-"), name)
+		assert.Contains(t, name, "-wt32-")
+		assert.Contains(t, name, "-m50-")
+		assert.True(t, perfTest.runConf.testConf.nbReadThreads >= 4, name)
+		assert.True(t, perfTest.mapTypeName == "fredMap" || perfTest.mapTypeName == "syncMap", name)
+	}
+
+	// The whole run name should match
+	assert.NoError(t, SelectRuns("int3d-c50"))
+	assert.Equal(t, 0, len(getAllRunnableTests()))
+
+	assert.Error(t, SelectRuns("int3d-c50-("))
+	assert.Error(t, SelectMapTypes([]string{"hashMap"}))
+	assert.Error(t, AddWhereConditions("percent miss=0.5,threads>1"))
+}
@@ -0,0 +1,290 @@
+package maptester
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/google/logger"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// Flat data file format, made to be memory mapped and used without decoding:
+// 32 bytes header with magic, version byte, padding, the size of a value record as uint16,
+// the number of lines n, the size and the seed of the source data file as uint64,
+// then n fixed width keys of 3 int64, then n value records of the offset of the value string
+// relative to the start of the strings and its length as uint64, then all the value strings bytes.
+// All numbers are little endian, the value Idx is always the line index.
+const (
+	FlatFileMagic      = "MAPF"
+	FlatFileVersion    = 3
+	FlatFileHeaderSize = 32
+	Int3KeySize        = int(unsafe.Sizeof(Int3Key{}))
+	ValueRecordSize    = 16
+)
+
+// flatSource identifies the data file a flat file was converted from
+type flatSource struct {
+	fileSize int64
+	seed     int64
+}
+
+func readFlatSource(dataFilename string) (flatSource, error) {
+	stat, err := os.Stat(dataFilename)
+	if err != nil {
+		return flatSource{}, err
+	}
+	dataReader, err := openDataFile(dataFilename)
+	if err != nil {
+		return flatSource{}, err
+	}
+	defer dataReader.close()
+	return flatSource{fileSize: stat.Size(), seed: dataReader.header.GetSeed()}, nil
+}
+
+// errStaleFlatFile is returned when the flat file was not converted from the current data file
+// or in the current format
+type errStaleFlatFile struct {
+	version byte
+	source  flatSource
+}
+
+func (e errStaleFlatFile) Error() string {
+	if e.version != FlatFileVersion {
+		return fmt.Sprintf("format version %d instead of %d", e.version, FlatFileVersion)
+	}
+	return fmt.Sprintf("converted from a data file of size %d and seed %d", e.source.fileSize, e.source.seed)
+}
+
+// Keys and values are used in place only if the memory layout is the file layout
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+var native64Bits = unsafe.Sizeof(uintptr(0)) == 8
+
+func getFlatFilename(name string, size int) string {
+	return filepath.Join(utils.GetGenDataDir(), fmt.Sprintf("%s-%d.flat", name, size))
+}
+
+// offsetWriter writes sequentially to a file starting at a given offset
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.file.WriteAt(p, ow.offset)
+	ow.offset += int64(n)
+	return n, err
+}
+
+// ConvertIntData converts an existing data file in the flat format used by the memory mapped reader
+func ConvertIntData(name string, size int) bool {
+	dataFilename := findDataFilename(name, size)
+	report := ReadIntDataFileReport(name, size)
+	if report == nil {
+		return false
+	}
+	flatFilename := getFlatFilename(name, size)
+	fmt.Printf("Converting %s to %s\n", dataFilename, flatFilename)
+	perf := NewStopWatch()
+	err := convertDataFile(dataFilename, flatFilename, int(report.NbLines))
+	if err != nil {
+		logger.Errorf("Cannot convert data file %s due to %v", dataFilename, err)
+		return false
+	}
+	perf.setNbLines(int(report.NbLines))
+	perf.stop()
+	perf.display(fmt.Sprintf("%s converted", name))
+	return true
+}
+
+// mapFlatDataFile maps the flat file of a data set, converting it again if the data file changed
+func mapFlatDataFile(name string, size int, dataFilename, flatFilename string, report *DataFileReport) (*IntMapTestDataSet, error) {
+	source, err := readFlatSource(dataFilename)
+	if err != nil {
+		return nil, err
+	}
+	im, err := mapFlatData(flatFilename, report, source)
+	var stale errStaleFlatFile
+	if errors.As(err, &stale) {
+		logger.Infof("Flat file %s is stale: %v. Converting it again.", flatFilename, err)
+		if !ConvertIntData(name, size) {
+			return nil, err
+		}
+		im, err = mapFlatData(flatFilename, report, source)
+	}
+	return im, err
+}
+
+func convertDataFile(dataFilename, flatFilename string, nbLines int) error {
+	source, err := readFlatSource(dataFilename)
+	if err != nil {
+		return err
+	}
+	dataReader, err := openDataFile(dataFilename)
+	if err != nil {
+		return err
+	}
+	defer dataReader.close()
+
+	tmpFilename := flatFilename + ".tmp"
+	flatFile, err := os.OpenFile(tmpFilename, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0665)
+	if err != nil {
+		return err
+	}
+	keysOffset := int64(FlatFileHeaderSize)
+	valuesOffset := keysOffset + int64(nbLines*Int3KeySize)
+	stringsOffset := valuesOffset + int64(nbLines*ValueRecordSize)
+	header := make([]byte, FlatFileHeaderSize)
+	copy(header, FlatFileMagic)
+	header[len(FlatFileMagic)] = FlatFileVersion
+	binary.LittleEndian.PutUint16(header[6:], uint16(ValueRecordSize))
+	binary.LittleEndian.PutUint64(header[8:], uint64(nbLines))
+	binary.LittleEndian.PutUint64(header[16:], uint64(source.fileSize))
+	binary.LittleEndian.PutUint64(header[24:], uint64(source.seed))
+	_, err = flatFile.WriteAt(header, 0)
+	utils.ExitOnError(err)
+
+	keysOut := bufio.NewWriterSize(&offsetWriter{flatFile, keysOffset}, DataFileBufferSize)
+	valuesOut := bufio.NewWriterSize(&offsetWriter{flatFile, valuesOffset}, DataFileBufferSize)
+	stringsOut := bufio.NewWriterSize(&offsetWriter{flatFile, stringsOffset}, DataFileBufferSize)
+	buf := make([]byte, Int3KeySize)
+	record := make([]byte, ValueRecordSize)
+	stringPos := uint64(0)
+	imLine := new(IntTestLine)
+	for i := 0; i < nbLines; i++ {
+		found, err := dataReader.nextLine(imLine)
+		if err == nil && !found {
+			err = fmt.Errorf("end of file at line %d", i)
+		}
+		if err == nil && (len(imLine.GetKey()) != 3 || imLine.GetValue().GetIdx() != int64(i)) {
+			err = fmt.Errorf("line %d has key %v and value index %d", i, imLine.GetKey(), imLine.GetValue().GetIdx())
+		}
+		if err != nil {
+			utils.CloseFile(flatFile)
+			utils.DeleteFile(tmpFilename)
+			return err
+		}
+		for k := 0; k < 3; k++ {
+			binary.LittleEndian.PutUint64(buf[k*8:], uint64(imLine.GetKey()[k]))
+		}
+		_, err = keysOut.Write(buf)
+		utils.ExitOnError(err)
+		n, err := stringsOut.WriteString(imLine.GetValue().GetSVal())
+		utils.ExitOnError(err)
+		binary.LittleEndian.PutUint64(record, stringPos)
+		binary.LittleEndian.PutUint64(record[8:], uint64(n))
+		_, err = valuesOut.Write(record)
+		utils.ExitOnError(err)
+		stringPos += uint64(n)
+	}
+	utils.ExitOnError(keysOut.Flush())
+	utils.ExitOnError(valuesOut.Flush())
+	utils.ExitOnError(stringsOut.Flush())
+	utils.CloseFile(flatFile)
+	return os.Rename(tmpFilename, flatFilename)
+}
+
+// mapFlatData maps a flat data file in memory. The keys and the value strings are used in place
+// in the read only mapping, but the TestValue records are allocated and filled from the offsets.
+func mapFlatData(flatFilename string, report *DataFileReport, source flatSource) (*IntMapTestDataSet, error) {
+	if !nativeLittleEndian || !native64Bits {
+		return nil, fmt.Errorf("flat data files can only be mapped on 64 bits little endian platforms")
+	}
+	data, err := mapFile(flatFilename)
+	if err != nil {
+		return nil, err
+	}
+	im, err := newFlatDataSet(data, report, source)
+	if err != nil {
+		_ = unmapFile(data)
+		return nil, fmt.Errorf("invalid flat data file %s: %w", flatFilename, err)
+	}
+	return im, nil
+}
+
+func newFlatDataSet(data []byte, report *DataFileReport, source flatSource) (*IntMapTestDataSet, error) {
+	if len(data) < FlatFileHeaderSize || string(data[:len(FlatFileMagic)]) != FlatFileMagic {
+		return nil, fmt.Errorf("no flat file header")
+	}
+	version := data[len(FlatFileMagic)]
+	if version > FlatFileVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	if version != FlatFileVersion {
+		return nil, errStaleFlatFile{version: version}
+	}
+	recordSize := int(binary.LittleEndian.Uint16(data[6:]))
+	if recordSize != ValueRecordSize {
+		return nil, fmt.Errorf("value records of %d bytes instead of %d", recordSize, ValueRecordSize)
+	}
+	fileSource := flatSource{
+		fileSize: int64(binary.LittleEndian.Uint64(data[16:])),
+		seed:     int64(binary.LittleEndian.Uint64(data[24:])),
+	}
+	if fileSource != source {
+		return nil, errStaleFlatFile{version, fileSource}
+	}
+	nbLines := int(binary.LittleEndian.Uint64(data[8:]))
+	if nbLines != int(report.NbLines) {
+		return nil, fmt.Errorf("file has %d lines but report has %d", nbLines, report.NbLines)
+	}
+	keysOffset := FlatFileHeaderSize
+	valuesOffset := keysOffset + nbLines*Int3KeySize
+	stringsOffset := valuesOffset + nbLines*ValueRecordSize
+	if len(data) < stringsOffset {
+		return nil, fmt.Errorf("file too small for %d lines", nbLines)
+	}
+	im := new(IntMapTestDataSet)
+	im.size = nbLines
+	im.mapped = data
+	if nbLines == 0 {
+		return im, nil
+	}
+	strings := data[stringsOffset:]
+	im.keys = unsafe.Slice((*Int3Key)(unsafe.Pointer(&data[keysOffset])), nbLines)
+	im.values = make([]TestValue, nbLines)
+	for i := 0; i < nbLines; i++ {
+		record := data[valuesOffset+i*ValueRecordSize:]
+		start := binary.LittleEndian.Uint64(record)
+		length := binary.LittleEndian.Uint64(record[8:])
+		if start > uint64(len(strings)) || length > uint64(len(strings))-start {
+			return nil, fmt.Errorf("invalid value record at line %d", i)
+		}
+		im.values[i].Idx = int64(i)
+		im.values[i].SVal = bytesAsString(strings[start : start+length])
+	}
+	return im, nil
+}
+
+// FlatValuesBytes is the memory allocated for the TestValue records of a mapped flat file of nbLines
+func FlatValuesBytes(nbLines int) int64 {
+	return int64(nbLines) * int64(unsafe.Sizeof(TestValue{}))
+}
+
+// bytesAsString returns a string sharing the bytes of b, which must never be modified
+func bytesAsString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// close releases the memory mapped file if any. The data set cannot be used after.
+func (im *IntMapTestDataSet) close() {
+	if im.mapped == nil {
+		return
+	}
+	err := unmapFile(im.mapped)
+	if err != nil {
+		logger.Errorf("Cannot unmap data set due to %v", err)
+	}
+	im.mapped = nil
+	im.keys = nil
+	im.values = nil
+}
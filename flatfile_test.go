@@ -0,0 +1,68 @@
+package maptester
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestFlatFileConvertAndMap(t *testing.T) {
+	dir := t.TempDir()
+	dataFilename := filepath.Join(dir, "test.data")
+	flatFilename := filepath.Join(dir, "test.flat")
+	size := NbLinesPerThreads + 10
+	gen := writeTestDataFile(t, dataFilename, size, 20)
+
+	assert.NoError(t, convertDataFile(dataFilename, flatFilename, size))
+	assert.Error(t, convertDataFile(dataFilename, flatFilename, size+1))
+
+	source, err := readFlatSource(dataFilename)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), source.seed)
+	_, err = mapFlatData(flatFilename, &DataFileReport{NbLines: int32(size - 1)}, source)
+	assert.Error(t, err)
+	stale := source
+	stale.fileSize++
+	_, err = mapFlatData(flatFilename, &DataFileReport{NbLines: int32(size)}, stale)
+	assert.True(t, errors.As(err, &errStaleFlatFile{}))
+	im, err := mapFlatData(flatFilename, &DataFileReport{NbLines: int32(size)}, source)
+	assert.NoError(t, err)
+	assert.Equal(t, size, im.size)
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		assert.Equal(t, key, im.getKey(i))
+		assert.Equal(t, value.SVal, im.values[i].SVal)
+		assert.Equal(t, int64(i), im.values[i].Idx)
+	}
+	im.close()
+	assert.Nil(t, im.keys)
+	assert.Nil(t, im.values)
+
+	flatFile, err := os.OpenFile(flatFilename, os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = flatFile.WriteAt([]byte{FlatFileVersion - 1}, int64(len(FlatFileMagic)))
+	assert.NoError(t, err)
+	assert.NoError(t, flatFile.Close())
+	_, err = mapFlatData(flatFilename, &DataFileReport{NbLines: int32(size)}, source)
+	assert.True(t, errors.As(err, &errStaleFlatFile{}))
+}
+
+// The flat files store the keys in the Int3Key layout and only the SVal and Idx fields of the values.
+// This fails if the layout or the fields change, the flat format and FlatFileVersion must then change too.
+func TestFlatFileLayout(t *testing.T) {
+	assert.Equal(t, 24, Int3KeySize)
+	assert.Equal(t, uintptr(8), unsafe.Alignof(Int3Key{}))
+	var fields []string
+	valueType := reflect.TypeOf(TestValue{})
+	for i := 0; i < valueType.NumField(); i++ {
+		if !strings.HasPrefix(valueType.Field(i).Name, "XXX_") {
+			fields = append(fields, valueType.Field(i).Name+" "+valueType.Field(i).Type.String())
+		}
+	}
+	assert.Equal(t, []string{"SVal string", "Idx int64"}, fields)
+}
@@ -15,7 +15,6 @@ func TestFredMapBasic(t *testing.T) {
 	assert.Nil(t, val)
 	val = new(TestMapValue)
 	val.count = 1
-	val.overwritten = false
 	val.val = new(TestValue)
 	val.val.Idx = 45
 	val.val.SVal = "test value"
@@ -33,7 +32,6 @@ func TestFredMapBasic(t *testing.T) {
 	key3 := Int3Key{34567, 76543, 987643257}
 	val2 := new(TestMapValue)
 	val2.count = 1
-	val2.overwritten = false
 	val2.val = new(TestValue)
 	val2.val.Idx = 456789
 	val2.val.SVal = "test value 2"
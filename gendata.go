@@ -2,20 +2,26 @@ package maptester
 
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
 	"github.com/freddy33/maptester/utils"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/logger"
+	"hash/fnv"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
 )
 
 const (
 	MaxConThreads     = 64
 	NbLinesPerThreads = 128 * 3 * 5 * 7
-	GenDataSize       = MaxConThreads * NbLinesPerThreads
+	DefaultDataSize   = MaxConThreads * NbLinesPerThreads
 )
 
 var Dimensions = []string{
@@ -26,11 +32,18 @@ var Dimensions = []string{
 	"percent miss",
 	"r/w nb ratio",
 	"value size",
+	"data size",
+	"workload mix",
+	"scenario",
+	"gomaxprocs",
+	"gc percent",
+	"memory limit",
 }
 
 // Used in data generation
 var ConflictRatioValues = []float32{0.10, 0.25, 0.5, 0.7}
-var ValueSize = []int{12}
+var ValueSizes = []ValueSizeDistribution{FixedValueSizeDistribution(12)}
+var DataSizes = []int{DefaultDataSize}
 
 // Used in Perf Test Execution
 var KeyTypes = []string{"int3d", "string10", "string25"}
@@ -44,38 +57,84 @@ var NbReadWriteRatio = []int{2, 8, 16, 32, 64}
 
 var RatioToRun = float32(0.1)
 
-// Data file aggregate key type, conflict ratio and value size
+// Used in data generation: Max data files generated in parallel, and the seed all data sets seeds derive from.
+// Each generation counts the same keys in up to 16MB, spilling the conflict lines to disk for large data sets.
+const MaxDefaultGenWorkers = 4
+
+var GenWorkers = minInt(runtime.NumCPU(), MaxDefaultGenWorkers)
+var GenSeed = int64(0)
+
+// Use the memory mapped flat data files when they exists
+var UseFlatData = true
+
+// Generate data files with gzip compressed blocks
+var CompressData = false
+
+// Data file aggregate key type, conflict ratio, value size and data size
 var DataConfigurations map[string]*DataConfiguration
 var RunConfigurations map[string]*RunConfiguration
 
 type DataConfiguration struct {
 	dataFilename  string
+	dataSetName   string
 	keyType       string
 	conflictRatio float32
-	valueSize     int
+	valueSize     ValueSizeDistribution
+	size          int
+	// Imported data sets are not generated
+	imported bool
 }
 
 func (dc *DataConfiguration) fillDataFileName() {
-	dc.dataFilename = fmt.Sprintf("%s-c%02d-v%02d", dc.keyType, int(dc.conflictRatio*100.0), dc.valueSize)
+	dc.dataFilename = fmt.Sprintf("%s-c%02d-%s", dc.keyType, int(dc.conflictRatio*100.0), dc.valueSize.Name())
+	dc.dataSetName = fmt.Sprintf("%s-%d", dc.dataFilename, dc.size)
 }
 
+// GetDataFileName returns the name of the data files without the size
 func (dc *DataConfiguration) GetDataFileName() string {
 	return dc.dataFilename
 }
 
+// GetDataSetName returns the unique name of the data set including the size
+func (dc *DataConfiguration) GetDataSetName() string {
+	return dc.dataSetName
+}
+
+func (dc *DataConfiguration) GetSize() int {
+	return dc.size
+}
+
+// seed returns the data generation seed, same seed and configuration generate the same data file
+func (dc *DataConfiguration) seed() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(dc.dataFilename))
+	return int64(h.Sum64()) ^ GenSeed
+}
+
 type RunConfiguration struct {
 	// Aggregate data file name and all other dimensions
 	runName              string
 	dataConf             *DataConfiguration
 	readWriteThreadRatio float32
 	readWriteNbRatio     int
+	mix                  *WorkloadMix
+	scenario             string
+	runtime              runtimeSettings
 	testConf             *MapTestConf
 }
 
 func (rc *RunConfiguration) fillRunName() {
-	rc.runName = fmt.Sprintf("%s-ir%02d-rt%02d-wt%02d-rwr%02d-m%02d", rc.dataConf.GetDataFileName(),
+	rc.runName = fmt.Sprintf("%s-ir%02d-rt%02d-wt%02d-rwr%02d-m%02d-%s%s", rc.dataConf.GetDataSetName(),
 		int(rc.testConf.initRatio*100.0), rc.testConf.nbReadThreads, rc.testConf.nbWriteThreads,
-		rc.readWriteNbRatio, int(rc.testConf.percentMiss*100.0))
+		rc.readWriteNbRatio, int(rc.testConf.percentMiss*100.0), rc.workloadName(), rc.runtime.nameSuffix())
+}
+
+// workloadName is the scenario of the populate mix, or the mix name
+func (rc *RunConfiguration) workloadName() string {
+	if rc.mix.isPopulate() {
+		return rc.scenario
+	}
+	return rc.mix.Name()
 }
 
 func (rc *RunConfiguration) GetRunName() string {
@@ -83,25 +142,59 @@ func (rc *RunConfiguration) GetRunName() string {
 }
 
 func init() {
+	BuildConfigurations()
+}
+
+// SelectDataSizes replaces the data sizes dimension and rebuilds all configurations
+func SelectDataSizes(sizes []int) {
+	DataSizes = sizes
+	BuildConfigurations()
+}
+
+// SelectValueSizes replaces the value size distributions dimension and rebuilds all configurations
+func SelectValueSizes(valueSizes []ValueSizeDistribution) {
+	ValueSizes = valueSizes
+	BuildConfigurations()
+}
+
+// SelectWorkloadMixes replaces the workload mixes dimension and rebuilds all configurations
+func SelectWorkloadMixes(mixes []*WorkloadMix) {
+	WorkloadMixes = mixes
+	BuildConfigurations()
+}
+
+// SelectScenarios replaces the scenarios of the populate mix and rebuilds all configurations
+func SelectScenarios(scenarios []string) {
+	Scenarios = scenarios
+	BuildConfigurations()
+}
+
+// BuildConfigurations creates all data and run configurations from the dimension values
+func BuildConfigurations() {
 	DataConfigurations = make(map[string]*DataConfiguration)
 	for crIdx, cr := range ConflictRatioValues {
 		for _, kt := range KeyTypes {
-			for vsIdx, vs := range ValueSize {
+			for vsIdx, vs := range ValueSizes {
 				// Testing different value size only for highest index conflicts ratio
 				if vsIdx > 0 && crIdx != len(ConflictRatioValues)-1 {
 					continue
 				}
-				dc := DataConfiguration{
-					keyType:       kt,
-					conflictRatio: cr,
-					valueSize:     vs,
+				for _, size := range DataSizes {
+					dc := DataConfiguration{
+						keyType:       kt,
+						conflictRatio: cr,
+						valueSize:     vs,
+						size:          size,
+					}
+					dc.fillDataFileName()
+					DataConfigurations[dc.GetDataSetName()] = &dc
 				}
-				dc.fillDataFileName()
-				DataConfigurations[dc.GetDataFileName()] = &dc
 			}
 		}
 	}
+	addImportedConfigurations()
 	RunConfigurations = make(map[string]*RunConfiguration)
+	settings := allRuntimeSettings()
 	for _, dc := range DataConfigurations {
 		for _, ir := range InitRatioValues {
 			for _, nbrt := range NbReadThreads {
@@ -109,21 +202,33 @@ func init() {
 					readWriteThreadRatio := float32(nbrt) / float32(nbwt)
 					for _, pm := range PercentMissValues {
 						for _, rwr := range NbReadWriteRatio {
-							nbReadTest := int(GenDataSize * rwr / nbrt)
-							rc := RunConfiguration{
-								dataConf:             dc,
-								readWriteThreadRatio: readWriteThreadRatio,
-								readWriteNbRatio:     rwr,
-								testConf: &MapTestConf{
-									nbWriteThreads: nbwt,
-									nbReadThreads:  nbrt,
-									nbReadTest:     nbReadTest,
-									initRatio:      ir,
-									percentMiss:    pm,
-								},
+							nbReadTest := int(dc.size * rwr / nbrt)
+							for _, mix := range WorkloadMixes {
+								for _, scenario := range mix.scenarios() {
+									for _, rs := range settings {
+										rc := RunConfiguration{
+											dataConf:             dc,
+											readWriteThreadRatio: readWriteThreadRatio,
+											readWriteNbRatio:     rwr,
+											mix:                  mix,
+											scenario:             scenario,
+											runtime:              rs,
+											testConf: &MapTestConf{
+												nbWriteThreads: nbwt,
+												nbReadThreads:  nbrt,
+												nbReadTest:     nbReadTest,
+												initRatio:      ir,
+												percentMiss:    pm,
+											},
+										}
+										rc.fillRunName()
+										if !matchesAll(Constraints, &rc) {
+											continue
+										}
+										RunConfigurations[rc.GetRunName()] = &rc
+									}
+								}
 							}
-							rc.fillRunName()
-							RunConfigurations[rc.GetRunName()] = &rc
 						}
 					}
 				}
@@ -134,41 +239,87 @@ func init() {
 
 func DisplayConfigurations() {
 	nbInt3d := 0
+	nbImported := 0
 	for _, dc := range DataConfigurations {
 		if dc.keyType == "int3d" {
 			nbInt3d++
 		}
+		if dc.imported {
+			nbImported++
+		}
+	}
+	fmt.Printf("Generated %d data configurations for sizes %v, out of which %d done for int3d\n",
+		len(DataConfigurations)-nbImported, DataSizes, nbInt3d-nbImported)
+	if nbImported > 0 {
+		fmt.Printf("Imported %d data configurations\n", nbImported)
 	}
-	fmt.Printf("Generated %d data configurations, out of which %d done for int3d\n", len(DataConfigurations), nbInt3d)
 	fmt.Printf("Generated %d run configurations and will select %f out of it\n", len(RunConfigurations), RatioToRun)
 	allTests := getAllRunnableTests()
-	fmt.Printf("With maps got %d runnable tests: Which means %f hours\n", len(allTests), float32(len(allTests)*10)/(60.0*60.0))
+	fmt.Printf("With maps got %d runnable tests: Which means %f hours\n", len(allTests), estimatedSeconds(allTests)/(60.0*60.0))
 }
 
 func DeleteAllData() {
-	for name, _ := range DataConfigurations {
-		DeleteDataFiles(name)
+	for _, dc := range DataConfigurations {
+		if dc.imported {
+			// Cannot be regenerated
+			continue
+		}
+		DeleteDataFiles(dc.GetDataFileName(), dc.size)
 	}
 }
 
-func DeleteDataFiles(name string) {
-	utils.DeleteFile(getReportFilename(name, GenDataSize))
-	utils.DeleteFile(getDataFilename(name, GenDataSize))
+func DeleteDataFiles(name string, size int) {
+	utils.DeleteFile(getFlatFilename(name, size))
+	utils.DeleteFile(getReportFilename(name, size))
+	utils.DeleteFile(getDataFilename(name, size))
+	utils.DeleteFile(getDataFilename(name, size) + GzipFileSuffix)
+	removeFromCatalog(fmt.Sprintf("%s-%d", name, size))
 }
 
 func GenAllData() {
-	for name, dc := range DataConfigurations {
+	nbWorkers := GenWorkers
+	if nbWorkers < 1 {
+		nbWorkers = 1
+	}
+	toGenerate := make(chan *DataConfiguration)
+	wg := new(sync.WaitGroup)
+	wg.Add(nbWorkers)
+	for w := 0; w < nbWorkers; w++ {
+		go func() {
+			for dc := range toGenerate {
+				generated := generateIntDataMap(dc.GetDataFileName(), dc.keyType, dc.size, dc.conflictRatio, dc.valueSize, dc.seed())
+				if generated || !isCataloged(dc.GetDataSetName()) {
+					addToCatalog(dc)
+				}
+			}
+			wg.Done()
+		}()
+	}
+	for _, dc := range DataConfigurations {
 		// TODO: Support only int3d for now
-		if dc.keyType == KeyTypes[0] {
-			generateIntDataMap(name, GenDataSize, dc.conflictRatio, dc.valueSize)
+		if dc.keyType == KeyTypes[0] && !dc.imported {
+			toGenerate <- dc
 		}
 	}
+	close(toGenerate)
+	wg.Wait()
 }
 
 func getDataFilename(name string, size int) string {
 	return filepath.Join(utils.GetGenDataDir(), fmt.Sprintf("%s-%d.data", name, size))
 }
 
+// ConvertAllData converts all existing data files to flat files
+func ConvertAllData() bool {
+	allGood := true
+	for _, dc := range DataConfigurations {
+		if utils.FileExists(findDataFilename(dc.GetDataFileName(), dc.size)) {
+			allGood = ConvertIntData(dc.GetDataFileName(), dc.size) && allGood
+		}
+	}
+	return allGood
+}
+
 func getReportFilename(name string, size int) string {
 	return filepath.Join(utils.GetGenDataDir(), fmt.Sprintf("%s-%d-report.data", name, size))
 }
@@ -184,7 +335,7 @@ func ReadIntDataFileReport(name string, size int) *DataFileReport {
 }
 
 func ReadIntData(name string, size int) (*IntMapTestDataSet, *DataFileReport) {
-	dataFilename := getDataFilename(name, size)
+	dataFilename := findDataFilename(name, size)
 	reportFilename := getReportFilename(name, size)
 
 	if !utils.FileExists(dataFilename) || !utils.FileExists(reportFilename) {
@@ -201,34 +352,29 @@ func ReadIntData(name string, size int) (*IntMapTestDataSet, *DataFileReport) {
 
 	perf := NewStopWatch()
 	result := readResults(reportFilename)
+	flatFilename := getFlatFilename(name, size)
+	if UseFlatData && utils.FileExists(flatFilename) {
+		im, err := mapFlatDataFile(name, size, dataFilename, flatFilename, result)
+		if err == nil {
+			perf.setNbLines(im.size)
+			perf.stop()
+			perf.display(fmt.Sprintf("%s mapped with %d KB of values allocated", name, FlatValuesBytes(im.size)>>10))
+			return im, result
+		}
+		logger.Errorf("Cannot map flat file %s due to %v. Reading data file.", flatFilename, err)
+	}
 	im := new(IntMapTestDataSet)
 	im.size = int(result.NbLines)
 	im.keys = make([]Int3Key, im.size)
 	im.values = make([]TestValue, im.size)
 
-	dataFile, err := os.Open(dataFilename)
+	readStart := time.Now()
+	stats, err := readDataSegments(dataFilename, im, result)
 	if err != nil {
-		logger.Fatalf("Cannot open data file %s due to %v", dataFilename, err)
-	}
-	defer utils.CloseFile(dataFile)
-	dataReader := bufio.NewReaderSize(dataFile, 8192)
-
-	for i := 0; i < im.size; i++ {
-		data := utils.ReadDataBlockPrefixSize(dataReader)
-		if data == nil {
-			logger.Errorf("Got end of file too early in %s pos %d", dataFilename, i)
-			break
-		}
-		imLine := new(IntTestLine)
-		err = proto.Unmarshal(data, imLine)
-		if err != nil {
-			logger.Fatalf("Cannot read line in data file %s due to %v", dataFilename, err)
-		}
-		for k := 0; k < 3; k++ {
-			im.keys[i][k] = imLine.GetKey()[k]
-		}
-		im.values[i] = *imLine.GetValue()
+		logger.Errorf("Cannot read data file %s due to %v", dataFilename, err)
+		return nil, nil
 	}
+	stats.display(name, time.Since(readStart))
 
 	perf.setNbLines(im.size)
 	perf.stop()
@@ -253,119 +399,273 @@ func readResults(resultFilename string) *DataFileReport {
 	return result
 }
 
-func generateIntDataMap(name string, size int, conflictsRatio float32, valueStringSize int) {
+// generateIntDataMap generates the data and report files, returns false if they already exist
+func generateIntDataMap(name, keyType string, size int, conflictsRatio float32, valueSize ValueSizeDistribution, seed int64) bool {
 	resultFilename := getReportFilename(name, size)
 	dataFilename := getDataFilename(name, size)
 
-	if utils.FileExists(dataFilename) && utils.FileExists(resultFilename) {
+	if utils.FileExists(findDataFilename(name, size)) && utils.FileExists(resultFilename) {
 		logger.Infof("data for %s of size %d already done in %s and %s. Skipping generation.",
 			name, size, resultFilename, dataFilename)
-		return
+		return false
 	}
 
-	fmt.Printf("Generating int map %s of size %d with %v conflicts ratio and %d string length\n",
-		name, size, conflictsRatio, valueStringSize)
+	fmt.Printf("Generating int map %s of size %d with %v conflicts ratio and %s string length in %s\n",
+		name, size, conflictsRatio, valueSize.Name(), dataFilename)
 
 	perf := NewStopWatch()
-	im := createIntMapTest(size, conflictsRatio, valueStringSize)
-	perf.stop()
-	perf.display(fmt.Sprintf("%s in memory %d lines", name, size))
+	gen := newIntDataGenerator(size, conflictsRatio, valueSize, seed)
+	compression := ""
+	if CompressData {
+		compression = GzipCompression
+	}
+	writer := newDataFileWriter(dataFilename, &DataFileHeader{
+		Seed:                  seed,
+		KeyType:               keyType,
+		ConflictRatio:         conflictsRatio,
+		ValueSize:             int32(valueSize.Size),
+		ValueSizeDistribution: valueSize.Name(),
+		NbLines:               int32(size),
+		Compression:           compression,
+	})
+	sameKeys := newSameKeysHistogram(size, dataFilename)
+	for i := 0; i < size; i++ {
+		root, key, value := gen.line(i)
+		sameKeys.add(i, root)
+		writer.writeLine(key, &value)
+	}
+	offsetsPerThreads := writer.close()
 
-	perf.init()
-	fmt.Printf("Dumping int map in %s and calculating assert values\n", dataFilename)
-	mapTestResult := writeDataFile(dataFilename, im)
+	// The not key of a line can never be a key of the data set, since the
+	// middle coordinate of each new key is unique. See intDataGenerator.keyOf
+	mapTestResult := newDataFileReport(size, sameKeys.counts(), offsetsPerThreads)
+	writer.fillReport(mapTestResult)
 	length := writeResultFile(resultFilename, mapTestResult)
 	fmt.Println("Result file", resultFilename, "saved with", length)
+	perf.setNbLines(size)
 	perf.stop()
 	perf.display(fmt.Sprintf("%s saved %d lines", name, size))
+	return true
 }
 
-func createIntMapTest(size int, conflictsRatio float32, valueStringSize int) *IntMapTestDataSet {
-	im := IntMapTestDataSet{
-		size,
-		make([]Int3Key, size),
-		make([]TestValue, size)}
-	for i := 0; i < im.size; i++ {
-		// Each line is a different value
-		im.values[i] = TestValue{SVal: randomString(valueStringSize), Idx: int64(i)}
-
-		if i > int(float32(size)*conflictsRatio)/2 && rand.Float32() < conflictsRatio {
-			// Let's generate a conflict
-			previousKeyIndex := int(rand.Int31n(int32(i)))
-			im.keys[i] = im.keys[previousKeyIndex]
-		} else {
-			im.keys[i] = [3]int64{}
-			for k := 0; k < 3; k++ {
-				im.keys[i][k] = rand.Int63()
-			}
-		}
+/********************************************
+Int data set generation
+*********************************************/
+
+// The number of root lines of a bucket of the same keys histogram, 16MB of counts
+var sameKeysRootsPerBucket = 1 << 22
+
+// sameKeysHistogram counts the lines using each key from the conflict chains, in a bounded memory.
+// Up to sameKeysRootsPerBucket lines, the conflict lines of each root are counted in memory.
+// Above, the root of each conflict line is spilled in the file of its bucket of roots,
+// and the buckets are counted one at a time after the generation.
+type sameKeysHistogram struct {
+	nbRoots     int
+	rootCounts  []int32
+	spillPrefix string
+	spills      []*os.File
+	spillOuts   []*bufio.Writer
+}
+
+func newSameKeysHistogram(size int, spillPrefix string) *sameKeysHistogram {
+	h := &sameKeysHistogram{spillPrefix: spillPrefix}
+	nbBuckets := (size + sameKeysRootsPerBucket - 1) / sameKeysRootsPerBucket
+	if nbBuckets > 1 {
+		h.spills = make([]*os.File, nbBuckets)
+		h.spillOuts = make([]*bufio.Writer, nbBuckets)
+	} else {
+		h.rootCounts = make([]int32, size)
 	}
-	return &im
+	return h
 }
 
-func writeDataFile(dataFilename string, im *IntMapTestDataSet) *DataFileReport {
-	result := make(map[Int3Key]int, im.size)
-	dataFile, err := os.OpenFile(dataFilename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0665)
-	if err != nil {
-		logger.Fatalf("Cannot open data file %s due to %v", dataFilename, err)
-	}
-	defer utils.CloseFile(dataFile)
-	countsSize := make(map[byte]int, 5)
-	offsetsPerThreads := make([]int32, MaxConThreads)
-	currentPos := int32(0)
-	currentThread := 0
-	for i := 0; i < im.size; i++ {
-		if i%NbLinesPerThreads == 0 {
-			offsetsPerThreads[currentThread] = currentPos
-			currentThread++
-		}
-		line := IntTestLine{Key: im.keys[i][:], Value: &im.values[i]}
-		data, err := proto.Marshal(&line)
-		if err != nil {
-			logger.Fatalf("Failed to marshall %v due to %v", line, err)
-		}
-		length := utils.WriteDataBlockPrefixSize(dataFile, data)
-		currentPos += int32(length) + 1
-		countsSize[length]++
-		result[im.keys[i]]++
-	}
-	fmt.Println(countsSize)
-
-	// Verify all the not key are not present
-	for i := 0; i < im.size; i++ {
-		notKey := im.getNotKey(i)
-		_, ok := result[notKey]
-		if ok {
-			logger.Fatalf("Found a not key %v!", notKey)
-		}
+func (h *sameKeysHistogram) add(line, root int) {
+	if root == line {
+		h.nbRoots++
+		return
 	}
+	if h.spills == nil {
+		h.rootCounts[root]++
+		return
+	}
+	bucket := root / sameKeysRootsPerBucket
+	if h.spills[bucket] == nil {
+		file, err := os.Create(h.spillFilename(bucket))
+		utils.ExitOnError(err)
+		h.spills[bucket] = file
+		h.spillOuts[bucket] = bufio.NewWriterSize(file, DataFileBufferSize)
+	}
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(root))
+	_, err := h.spillOuts[bucket].Write(buf[:])
+	utils.ExitOnError(err)
+}
 
-	max := 0
-	sameKeysCount := make(map[int]int32, 5)
-	for _, v := range result {
-		sameKeysCount[v]++
-		if v > max {
-			max = v
+func (h *sameKeysHistogram) spillFilename(bucket int) string {
+	return fmt.Sprintf("%s.roots-%d.tmp", h.spillPrefix, bucket)
+}
+
+// counts returns the number of keys by number of lines using them, and deletes the spill files
+func (h *sameKeysHistogram) counts() map[int]int32 {
+	result := make(map[int]int32, 5)
+	nbConflictRoots := 0
+	addCounts := func(rootCounts []int32) {
+		for _, nbConflicts := range rootCounts {
+			if nbConflicts > 0 {
+				result[int(nbConflicts)+1]++
+				nbConflictRoots++
+			}
 		}
 	}
-	mapTestResult := new(DataFileReport)
-	mapTestResult.NbLines = int32(im.size)
-	mapTestResult.NbEntries = int32(len(result))
-	mapTestResult.NbSameKeys = mapTestResult.NbLines - mapTestResult.NbEntries
-	if max > 1 {
-		mapTestResult.NbOfTimesSameKey = make([]int32, max-1)
-		for k, v := range sameKeysCount {
-			if k > 1 {
-				mapTestResult.NbOfTimesSameKey[k-2] = v
+	if h.spills == nil {
+		addCounts(h.rootCounts)
+	} else {
+		rootCounts := make([]int32, sameKeysRootsPerBucket)
+		for bucket, file := range h.spills {
+			if file == nil {
+				continue
+			}
+			utils.ExitOnError(h.spillOuts[bucket].Flush())
+			_, err := file.Seek(0, io.SeekStart)
+			utils.ExitOnError(err)
+			for i := range rootCounts {
+				rootCounts[i] = 0
+			}
+			in := bufio.NewReaderSize(file, DataFileBufferSize)
+			var buf [4]byte
+			for {
+				_, err := io.ReadFull(in, buf[:])
+				if err == io.EOF {
+					break
+				}
+				utils.ExitOnError(err)
+				rootCounts[int(binary.LittleEndian.Uint32(buf[:]))%sameKeysRootsPerBucket]++
 			}
+			utils.CloseFile(file)
+			utils.DeleteFile(h.spillFilename(bucket))
+			addCounts(rootCounts)
 		}
 	}
-	mapTestResult.OffsetsPerThreads = make([]int32, len(offsetsPerThreads))
-	for i, offset := range offsetsPerThreads {
-		mapTestResult.OffsetsPerThreads[i] = offset
+	if h.nbRoots > nbConflictRoots {
+		result[1] = int32(h.nbRoots - nbConflictRoots)
+	}
+	return result
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
 	}
+	return b
+}
+
+const (
+	mask63      = 0x7fffffffffffffff
+	keyStream   = 0x2545f4914f6cdd1d
+	valueStream = 0x5851f42d4c957f2d
+)
+
+// splitMixSource is a rand.Source64 cheap enough to be seeded for each line.
+type splitMixSource struct {
+	state uint64
+}
+
+func (s *splitMixSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMixSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix64(s.state)
+}
+
+func (s *splitMixSource) Int63() int64 {
+	return int64(s.Uint64() & mask63)
+}
+
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// mix63 is a bijection on 63 bits positive integers
+func mix63(z uint64) uint64 {
+	z &= mask63
+	z = ((z ^ (z >> 31)) * 0xbf58476d1ce4e5b9) & mask63
+	z = ((z ^ (z >> 29)) * 0x94d049bb133111eb) & mask63
+	return z ^ (z >> 32)
+}
+
+// intDataGenerator derives each line of an int data set only from the seed
+// and the line index. A conflict line reuses the key of a random previous
+// line, which is recomputed instead of kept in memory.
+type intDataGenerator struct {
+	size           int
+	conflictsRatio float32
+	valueSize      ValueSizeDistribution
+	seed           uint64
+	conflictsStart int
+	src            splitMixSource
+	rnd            *rand.Rand
+}
+
+func newIntDataGenerator(size int, conflictsRatio float32, valueSize ValueSizeDistribution, seed int64) *intDataGenerator {
+	gen := new(intDataGenerator)
+	gen.size = size
+	gen.conflictsRatio = conflictsRatio
+	gen.valueSize = valueSize
+	gen.seed = uint64(seed)
+	gen.conflictsStart = int(float32(size)*conflictsRatio) / 2
+	gen.rnd = rand.New(&gen.src)
+	return gen
+}
+
+func (gen *intDataGenerator) seedLine(i int, stream uint64) {
+	gen.src.state = mix64(gen.seed ^ mix64(uint64(i)+stream))
+}
+
+// conflictOf returns the previous line index which key line i is using, or -1 for a new key
+func (gen *intDataGenerator) conflictOf(i int) int {
+	if i <= gen.conflictsStart {
+		return -1
+	}
+	gen.seedLine(i, keyStream)
+	if gen.rnd.Float32() < gen.conflictsRatio {
+		return int(gen.rnd.Int31n(int32(i)))
+	}
+	return -1
+}
+
+// rootOf returns the first line index using the same key as line i
+func (gen *intDataGenerator) rootOf(i int) int {
+	for {
+		previous := gen.conflictOf(i)
+		if previous < 0 {
+			return i
+		}
+		i = previous
+	}
+}
+
+// keyOf returns the key created at root line. The middle coordinate is a
+// bijection of the root index, so two new keys never share it.
+func (gen *intDataGenerator) keyOf(root int) Int3Key {
+	r := uint64(root)
+	return Int3Key{
+		int64(mix64(gen.seed^mix64(r)) & mask63),
+		int64(mix63(gen.seed ^ r)),
+		int64(mix64(gen.seed^mix64(r^mask63)) & mask63),
+	}
+}
 
-	return mapTestResult
+// line returns the root line index, the key and the value of line i
+func (gen *intDataGenerator) line(i int) (int, Int3Key, TestValue) {
+	root := gen.rootOf(i)
+	gen.seedLine(i, valueStream)
+	// Each line is a different value
+	value := TestValue{SVal: randomString(gen.rnd, gen.valueSize.draw(gen.rnd)), Idx: int64(i)}
+	return root, gen.keyOf(root), value
 }
 
 func writeResultFile(resultsFilename string, mapTestResult *DataFileReport) int {
@@ -381,24 +681,24 @@ func writeResultFile(resultsFilename string, mapTestResult *DataFileReport) int
 	return utils.WriteDataBlock(resultFile, data)
 }
 
-func randomString(size int) string {
+func randomString(rnd *rand.Rand, size int) string {
 	cb := make([]byte, size)
 	for i := 0; i < size; i++ {
-		cb[i] = randomChar()
+		cb[i] = randomChar(rnd)
 	}
 	return string(cb)
 }
 
-func randomChar() byte {
+func randomChar(rnd *rand.Rand) byte {
 	var result byte
 	// 10% capital letter, 20% space, 70% lowercase
-	t := rand.Float32()
+	t := rnd.Float32()
 	if t < 0.1 {
 		result = 0x20
 	} else if t < 0.3 {
-		result = byte(65 + rand.Int31n(26))
+		result = byte(65 + rnd.Int31n(26))
 	} else {
-		result = byte(97 + rand.Int31n(26))
+		result = byte(97 + rnd.Int31n(26))
 	}
 	return result
 }
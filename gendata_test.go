@@ -0,0 +1,71 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntDataGenerator(t *testing.T) {
+	size := 3 * NbLinesPerThreads
+	gen := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(12), 42)
+	other := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(12), 42)
+	keys := make(map[Int3Key]int, size)
+	nbConflicts := 0
+	for i := 0; i < size; i++ {
+		root, key, value := gen.line(i)
+		otherRoot, otherKey, otherValue := other.line(i)
+		assert.Equal(t, root, otherRoot)
+		assert.Equal(t, key, otherKey)
+		assert.Equal(t, value.SVal, otherValue.SVal)
+		assert.Equal(t, int64(i), value.Idx)
+		assert.Equal(t, 12, len(value.SVal))
+		assert.True(t, root <= i)
+		if root != i {
+			nbConflicts++
+			assert.Equal(t, keys[key], root)
+		} else {
+			_, found := keys[key]
+			assert.False(t, found, "new key %v at %d already used", key, i)
+			keys[key] = i
+		}
+	}
+	assert.Equal(t, size, len(keys)+nbConflicts)
+	assert.True(t, nbConflicts > size/10, "only %d conflicts", nbConflicts)
+	for i := 0; i < size; i++ {
+		_, key, _ := gen.line(i)
+		notKey := Int3Key{key[0] + 1, key[1], key[2] - 1}
+		_, found := keys[notKey]
+		assert.False(t, found, "not key %v of line %d is a key", notKey, i)
+	}
+}
+
+func TestSameKeysHistogram(t *testing.T) {
+	defer func(n int) { sameKeysRootsPerBucket = n }(sameKeysRootsPerBucket)
+	size := 10000
+	gen := newIntDataGenerator(size, 0.7, FixedValueSizeDistribution(12), 7)
+	sameKeys := make(map[int]int32)
+	for i := 0; i < size; i++ {
+		sameKeys[gen.rootOf(i)]++
+	}
+	expected := make(map[int]int32)
+	for _, v := range sameKeys {
+		expected[int(v)]++
+	}
+
+	// In memory, then spilled in 4 buckets
+	dir := t.TempDir()
+	for _, rootsPerBucket := range []int{size, 2600} {
+		sameKeysRootsPerBucket = rootsPerBucket
+		histogram := newSameKeysHistogram(size, filepath.Join(dir, "test.data"))
+		for i := 0; i < size; i++ {
+			histogram.add(i, gen.rootOf(i))
+		}
+		assert.Equal(t, rootsPerBucket < size, histogram.spills != nil)
+		assert.Equal(t, expected, histogram.counts())
+		files, err := ioutil.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Empty(t, files)
+	}
+}
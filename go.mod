@@ -5,7 +5,12 @@ require (
 	github.com/golang/protobuf v1.3.5
 	github.com/google/logger v1.0.1
 	github.com/stretchr/testify v1.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527 // indirect
 )
 
-go 1.13
+go 1.19
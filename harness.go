@@ -0,0 +1,213 @@
+package maptester
+
+import (
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/gocarina/gocsv"
+	"github.com/google/logger"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+// How the read threads choose their keys:
+// precomputed generates the sequence of each read thread before the timing starts,
+// local-rand draws the keys from a random source per thread,
+// shared-rand draws them from the global math/rand source locked on each call, the harness before precomputing.
+// A precomputed test reading too many keys falls back to local-rand, written local-rand-fallback in the CSV.
+const (
+	HarnessPrecomputed = "precomputed"
+	HarnessLocalRand   = "local-rand"
+	HarnessSharedRand  = "shared-rand"
+	HarnessFallback    = "local-rand-fallback"
+)
+
+var HarnessModes = []string{HarnessPrecomputed, HarnessLocalRand, HarnessSharedRand}
+
+// Harness is the requested harness, tests running until stopped use local-rand instead of precomputed,
+// and the ones reading more than MaxPrecomputedOps keys fall back to local-rand
+var Harness = HarnessPrecomputed
+
+// The maximum number of read operations of a test precomputed, 12 bytes each,
+// up to a quarter of the physical memory and at least 16M
+var MaxPrecomputedOps = precomputedOpsLimit(totalMemory())
+
+func precomputedOpsLimit(memory uint64) int {
+	limit := memory / 4 / uint64(unsafe.Sizeof(traceOp{}))
+	if limit < 1<<24 {
+		return 1 << 24
+	}
+	if limit > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(limit)
+}
+
+// SelectHarness sets the harness from its name
+func SelectHarness(value string) error {
+	if !containsString(HarnessModes, value) {
+		return fmt.Errorf("harness %q unknown, expected one of %s", value, strings.Join(HarnessModes, ","))
+	}
+	Harness = value
+	return nil
+}
+
+// harnessMode returns the harness used by the test.
+// The workload mixes always use a random source per thread.
+func (mp *MapPerfTestResult) harnessMode() string {
+	if !mp.runConf.mix.isPopulate() {
+		return HarnessLocalRand
+	}
+	if Harness == HarnessPrecomputed {
+		conf := mp.runConf.testConf
+		if TestDuration > 0 {
+			return HarnessLocalRand
+		}
+		if nbReads := conf.nbReadThreads * conf.nbReadTest; nbReads > MaxPrecomputedOps {
+			logger.Warningf("Test %s reads %d keys, more than the %d precomputed. Using the %s harness.",
+				mp.Name(), nbReads, MaxPrecomputedOps, HarnessLocalRand)
+			return HarnessFallback
+		}
+	}
+	return Harness
+}
+
+// readHarness is what the read threads of a populate test use to choose their keys
+type readHarness struct {
+	replay    bool
+	sequences [][]traceOp
+	rnds      []*rand.Rand
+}
+
+// prepareReads selects the harness of the test and generates the precomputed reads,
+// before the heap baseline and the timing. Operations are random unless replaying a trace.
+func (mp *MapPerfTestResult) prepareReads(im *IntMapTestDataSet) *readHarness {
+	conf := mp.runConf.testConf
+	reads := &readHarness{replay: mp.trace != nil && !mp.recordTrace}
+	mp.harness = mp.harnessMode()
+	if reads.replay {
+		mp.harness = HarnessPrecomputed
+	} else if mp.harness == HarnessPrecomputed {
+		seed := rand.Int63()
+		if mp.recordTrace {
+			mp.trace = GenerateTrace(mp.runConf, im.size, seed)
+		} else {
+			rnd := rand.New(rand.NewSource(seed))
+			reads.sequences = make([][]traceOp, conf.nbReadThreads)
+			for i := range reads.sequences {
+				reads.sequences[i] = generateReadOps(rnd, im.size, conf.nbReadTest, conf.percentMiss)
+			}
+		}
+	} else if mp.recordTrace {
+		mp.trace = newRecordingTrace(mp.runConf, im.size)
+	}
+	reads.rnds = readerRands(mp.harness, conf.nbReadThreads)
+	return reads
+}
+
+// generateReadOps draws nbOps random reads of the lines, missing the key with the percent miss
+func generateReadOps(rnd *rand.Rand, nbLines, nbOps int, percentMiss float32) []traceOp {
+	ops := make([]traceOp, nbOps)
+	for j := range ops {
+		ops[j] = traceOp{
+			op:     TraceOpType_LOAD,
+			keyIdx: rnd.Int31n(int32(nbLines)),
+			miss:   rnd.Float32() < percentMiss,
+		}
+	}
+	return ops
+}
+
+// readerRands returns the random sources of the read threads, all nil with the shared-rand harness
+func readerRands(harness string, nbReadThreads int) []*rand.Rand {
+	rnds := make([]*rand.Rand, nbReadThreads)
+	if harness != HarnessSharedRand {
+		for i := range rnds {
+			rnds[i] = rand.New(rand.NewSource(rand.Int63()))
+		}
+	}
+	return rnds
+}
+
+// harnessOverhead returns the share of the shared-rand measurement spent in the harness compared to the other harness.
+// Tests running until stopped compare the read throughputs, the others the exec durations.
+func harnessOverhead(shared, other PerfLineMeasurement) float64 {
+	if shared.TargetDuration > 0 {
+		if other.ReadThroughput == 0 {
+			return 0
+		}
+		return 1 - shared.ReadThroughput/other.ReadThroughput
+	}
+	if shared.ExecDuration == 0 {
+		return 0
+	}
+	return float64(shared.ExecDuration-other.ExecDuration) / float64(shared.ExecDuration)
+}
+
+// CompareHarness displays, for the tests run with the shared-rand harness and another one,
+// the share of the shared-rand exec duration that was harness overhead.
+// The tests that fell back from precomputed to local-rand are not compared.
+func CompareHarness(fileNames []string) {
+	shared := make(map[PerfLineKey]PerfLine)
+	others := make(map[PerfLineKey]PerfLine)
+	keys := make([]PerfLineKey, 0)
+	nbFallbacks := 0
+	for _, filename := range fileNames {
+		file := filename
+		if !strings.ContainsRune(filename, '/') {
+			file = filepath.Join(utils.GetOutPerfDir(), filename)
+		}
+		perfFile, err := os.Open(file)
+		if err != nil {
+			logger.Fatalf("cannot open perf file %q due to %v", file, err)
+		}
+		err = gocsv.UnmarshalToCallback(perfFile, func(line PerfLine) {
+			if line.isFailed() || line.Harness == "" {
+				return
+			}
+			if line.Harness == HarnessFallback {
+				nbFallbacks++
+				return
+			}
+			key := line.PerfLineKey.resumeKey()
+			if line.Harness == HarnessSharedRand {
+				shared[key] = line
+			} else {
+				if _, ok := others[key]; !ok {
+					keys = append(keys, key)
+				}
+				others[key] = line
+			}
+		})
+		utils.CloseFile(perfFile)
+		if err != nil {
+			logger.Fatalf("cannot read perf file %q due to %v", file, err)
+		}
+	}
+
+	nbCompared := 0
+	totalOverhead := 0.0
+	fmt.Printf("%-70s %12s %12s %12s %9s\n", "test", "harness", "shared exec", "exec", "overhead")
+	for _, key := range keys {
+		before, ok := shared[key]
+		if !ok {
+			continue
+		}
+		after := others[key]
+		overhead := harnessOverhead(before.PerfLineMeasurement, after.PerfLineMeasurement)
+		fmt.Printf("%-70s %12s %12d %12d %8.1f%%\n", after.Name, after.Harness, before.ExecDuration, after.ExecDuration, 100*overhead)
+		nbCompared++
+		totalOverhead += overhead
+	}
+	if nbFallbacks > 0 {
+		fmt.Printf("%d tests fell back from %s to %s and are not compared\n", nbFallbacks, HarnessPrecomputed, HarnessLocalRand)
+	}
+	if nbCompared == 0 {
+		fmt.Println("No test run with the shared-rand harness and another harness")
+		return
+	}
+	fmt.Printf("Average harness overhead of %d tests: %.1f%%\n", nbCompared, 100*totalOverhead/float64(nbCompared))
+}
@@ -0,0 +1,65 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestSelectHarness(t *testing.T) {
+	defer func(harness string) { Harness = harness }(Harness)
+	assert.Error(t, SelectHarness("global"))
+	assert.NoError(t, SelectHarness(HarnessSharedRand))
+	assert.Equal(t, HarnessSharedRand, Harness)
+	assert.Nil(t, readerRands(HarnessSharedRand, 2)[1])
+	assert.NotNil(t, readerRands(HarnessLocalRand, 2)[1])
+}
+
+func TestHarnessModes(t *testing.T) {
+	defer func(harness string, maxOps int) { Harness, MaxPrecomputedOps = harness, maxOps }(Harness, MaxPrecomputedOps)
+	size := 1000
+	im, report := newTestDataSet(size)
+	newPerfTest := func() *MapPerfTestResult {
+		mp := &MapPerfTestResult{runConf: newTestRunConfiguration(size, 2, 2, 500), mapTypeName: "RWMutex"}
+		mp.fill(report)
+		return mp
+	}
+	for _, harness := range HarnessModes {
+		Harness = harness
+		mp := newPerfTest()
+		mp.testConcurrentMap(im)
+		assert.Equal(t, harness, mp.harness)
+		assert.Equal(t, 0, mp.totalErrors(), harness)
+		assert.Equal(t, 1000, mp.nbReadDone(), harness)
+	}
+
+	// Too many reads to precompute
+	Harness = HarnessPrecomputed
+	MaxPrecomputedOps = 999
+	mp := newPerfTest()
+	mp.testConcurrentMap(im)
+	assert.Equal(t, HarnessFallback, mp.harness)
+	assert.Equal(t, 0, mp.totalErrors())
+
+	// The recorded trace of a precomputed test is the generated one
+	MaxPrecomputedOps = 1000
+	mp = newPerfTest()
+	mp.recordTrace = true
+	mp.testConcurrentMap(im)
+	assert.Equal(t, HarnessPrecomputed, mp.harness)
+	assert.NotEqual(t, int64(0), mp.trace.header.Seed)
+	assert.Equal(t, GenerateTrace(mp.runConf, size, mp.trace.header.Seed).threads, mp.trace.threads)
+}
+
+func TestPrecomputedOpsLimit(t *testing.T) {
+	assert.Equal(t, 1<<24, precomputedOpsLimit(0))
+	assert.Equal(t, (4<<30)/12, precomputedOpsLimit(16<<30))
+	assert.Equal(t, math.MaxInt32, precomputedOpsLimit(1<<40))
+}
+
+func TestHarnessOverhead(t *testing.T) {
+	assert.InDelta(t, 0.25, harnessOverhead(PerfLineMeasurement{ExecDuration: 400}, PerfLineMeasurement{ExecDuration: 300}), 1e-9)
+	assert.InDelta(t, 0.5, harnessOverhead(PerfLineMeasurement{TargetDuration: 1000, ReadThroughput: 100},
+		PerfLineMeasurement{TargetDuration: 1000, ReadThroughput: 200}), 1e-9)
+	assert.Equal(t, 0.0, harnessOverhead(PerfLineMeasurement{}, PerfLineMeasurement{}))
+}
@@ -0,0 +1,340 @@
+package maptester
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/google/logger"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ImportInt3dKeys  = "int3d"
+	ImportStringKeys = "string"
+)
+
+// ImportedDataSet is the registration of an imported data file as a data configuration
+type ImportedDataSet struct {
+	Name          string  `json:"name"`
+	Source        string  `json:"source"`
+	SourceKeyType string  `json:"sourceKeyType"`
+	Size          int     `json:"size"`
+	ConflictRatio float32 `json:"conflictRatio"`
+	ValueSize     int     `json:"valueSize"`
+}
+
+func getImportedDataSetsFilename() string {
+	return filepath.Join(utils.GetGenDataDir(), "imported-datasets.json")
+}
+
+// The imported data sets added to the data configurations, loaded by LoadImportedDataSets
+var importedDataSets []ImportedDataSet
+
+// LoadImportedDataSets adds the imported data sets to the data configurations.
+// There are none outside a git checkout or without a gen data dir.
+func LoadImportedDataSets() {
+	importedDataSets = readImportedDataSets()
+	BuildConfigurations()
+}
+
+func readImportedDataSets() []ImportedDataSet {
+	result := make([]ImportedDataSet, 0)
+	// Should not create the gen data dir
+	gitRootDir, ok := utils.FindGitRootDir()
+	if !ok {
+		return result
+	}
+	if b, _ := utils.DirExists(gitRootDir, filepath.Join("build", "gendata")); !b {
+		return result
+	}
+	filename := getImportedDataSetsFilename()
+	if !utils.FileExists(filename) {
+		return result
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		logger.Errorf("Cannot read imported data sets file %s due to %v", filename, err)
+		return result
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Errorf("Cannot parse imported data sets file %s due to %v", filename, err)
+	}
+	return result
+}
+
+func writeImportedDataSets(imported []ImportedDataSet) {
+	data, err := json.MarshalIndent(imported, "", "  ")
+	utils.ExitOnError(err)
+	utils.ExitOnError(ioutil.WriteFile(getImportedDataSetsFilename(), data, 0644))
+	importedDataSets = imported
+}
+
+// addImportedConfigurations adds all imported data sets to the data configurations.
+// The keys of imported data sets are always int3d, string keys are hashed.
+func addImportedConfigurations() {
+	for _, imported := range importedDataSets {
+		dc := DataConfiguration{
+			keyType:       KeyTypes[0],
+			conflictRatio: imported.ConflictRatio,
+			valueSize:     FixedValueSizeDistribution(imported.ValueSize),
+			size:          imported.Size,
+			imported:      true,
+		}
+		dc.dataFilename = imported.Name
+		dc.dataSetName = fmt.Sprintf("%s-%d", dc.dataFilename, dc.size)
+		DataConfigurations[dc.GetDataSetName()] = &dc
+	}
+}
+
+// traceReader returns the fields of each line of a CSV, semicolon or whitespace separated file
+type traceReader struct {
+	csvReader *csv.Reader
+	scanner   *bufio.Scanner
+}
+
+func newTraceReader(file *os.File) (*traceReader, error) {
+	in := bufio.NewReader(file)
+	sample, err := in.Peek(4096)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	tr := new(traceReader)
+	firstLine := string(sample)
+	if idx := strings.IndexByte(firstLine, '\n'); idx >= 0 {
+		firstLine = firstLine[:idx]
+	}
+	if strings.ContainsAny(firstLine, ",;") {
+		tr.csvReader = csv.NewReader(in)
+		if !strings.ContainsRune(firstLine, ',') {
+			tr.csvReader.Comma = ';'
+		}
+		tr.csvReader.Comment = '#'
+		tr.csvReader.FieldsPerRecord = -1
+		tr.csvReader.TrimLeadingSpace = true
+	} else {
+		tr.scanner = bufio.NewScanner(in)
+		tr.scanner.Buffer(make([]byte, 64*1024), MaxDataBlockSize)
+	}
+	return tr, nil
+}
+
+// next returns the fields of the next non empty line, or nil at the end of the file
+func (tr *traceReader) next() ([]string, error) {
+	if tr.csvReader != nil {
+		for {
+			fields, err := tr.csvReader.Read()
+			if err == io.EOF {
+				return nil, nil
+			}
+			if err != nil || len(fields) > 1 || (len(fields) == 1 && fields[0] != "") {
+				return fields, err
+			}
+		}
+	}
+	for tr.scanner.Scan() {
+		line := strings.TrimSpace(tr.scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		return strings.Fields(line), nil
+	}
+	return nil, tr.scanner.Err()
+}
+
+// parseTraceLine extracts the key and the optional value of a trace line
+func parseTraceLine(fields []string, sourceKeyType string) (Int3Key, string, bool, error) {
+	var key Int3Key
+	switch sourceKeyType {
+	case ImportInt3dKeys:
+		if len(fields) < 3 {
+			return key, "", false, fmt.Errorf("expected 3 coordinates in %v", fields)
+		}
+		for k := 0; k < 3; k++ {
+			c, err := strconv.ParseInt(strings.TrimSpace(fields[k]), 10, 64)
+			if err != nil {
+				return key, "", false, err
+			}
+			key[k] = c
+		}
+		fields = fields[3:]
+	case ImportStringKeys:
+		key = stringToInt3Key(fields[0])
+		fields = fields[1:]
+	default:
+		return key, "", false, fmt.Errorf("key type %q not supported", sourceKeyType)
+	}
+	if len(fields) == 0 {
+		return key, "", false, nil
+	}
+	return key, strings.Join(fields, " "), true, nil
+}
+
+// stringToInt3Key hashes a string key since tests only support int3d keys for now
+func stringToInt3Key(s string) Int3Key {
+	var key Int3Key
+	h := fnv.New64a()
+	for k := 0; k < 3; k++ {
+		h.Reset()
+		_, _ = h.Write([]byte{byte(k)})
+		_, _ = h.Write([]byte(s))
+		key[k] = int64(h.Sum64() & mask63)
+	}
+	return key
+}
+
+// ImportTrace converts a file of keys with optional values into a data file and its report,
+// and registers it as a data configuration named name.
+func ImportTrace(sourceFilename, name, sourceKeyType string, seed int64) error {
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return fmt.Errorf("invalid import name %q", name)
+	}
+	for _, dc := range DataConfigurations {
+		if !dc.imported && dc.GetDataFileName() == name {
+			return fmt.Errorf("import name %q is a generated data configuration", name)
+		}
+	}
+	perf := NewStopWatch()
+	// First pass to count the lines needed in the data file header.
+	// The first line is skipped if it is a header.
+	size := 0
+	firstLineSkipped := false
+	err := scanTrace(sourceFilename, func(fields []string) error {
+		if size == 0 && !firstLineSkipped {
+			if _, _, _, err := parseTraceLine(fields, sourceKeyType); err != nil {
+				firstLineSkipped = true
+				return nil
+			}
+		}
+		size++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return fmt.Errorf("no lines found in %s", sourceFilename)
+	}
+
+	DeleteDataFiles(name, size)
+	dataFilename := getDataFilename(name, size)
+	reportFilename := getReportFilename(name, size)
+	fmt.Printf("Importing %d lines of %s keys from %s in %s\n", size, sourceKeyType, sourceFilename, dataFilename)
+	writer := newDataFileWriter(dataFilename, &DataFileHeader{
+		Seed:    seed,
+		KeyType: KeyTypes[0],
+		NbLines: int32(size),
+	})
+	rnd := rand.New(rand.NewSource(seed))
+	keyCounts := make(map[Int3Key]int, size)
+	i := 0
+	totalValueSize := 0
+	lineNumber := 0
+	err = scanTrace(sourceFilename, func(fields []string) error {
+		lineNumber++
+		if firstLineSkipped && lineNumber == 1 {
+			return nil
+		}
+		key, value, hasValue, err := parseTraceLine(fields, sourceKeyType)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		if !hasValue {
+			value = randomString(rnd, ValueSizes[0].draw(rnd))
+		}
+		totalValueSize += len(value)
+		writer.writeLine(key, &TestValue{SVal: value, Idx: int64(i)})
+		keyCounts[key]++
+		i++
+		return nil
+	})
+	offsetsPerThreads := writer.close()
+	if err != nil {
+		utils.DeleteFile(dataFilename)
+		return err
+	}
+
+	sameKeysCount := make(map[int]int32, 5)
+	notKeysFound := 0
+	for key, count := range keyCounts {
+		sameKeysCount[count]++
+		if _, ok := keyCounts[Int3Key{key[0] + 1, key[1], key[2] - 1}]; ok {
+			notKeysFound++
+		}
+	}
+	if notKeysFound > 0 {
+		logger.Warningf("%d imported keys are the not key of another key, tests with percent miss will report key found errors",
+			notKeysFound)
+	}
+	report := newDataFileReport(size, sameKeysCount, offsetsPerThreads)
+	writer.fillReport(report)
+	writeResultFile(reportFilename, report)
+
+	imported := ImportedDataSet{
+		Name:          name,
+		Source:        utils.AbsPath(sourceFilename),
+		SourceKeyType: sourceKeyType,
+		Size:          size,
+		ConflictRatio: float32(report.NbSameKeys) / float32(report.NbLines),
+		ValueSize:     totalValueSize / size,
+	}
+	allImported := readImportedDataSets()
+	replaced := false
+	for idx := range allImported {
+		if allImported[idx].Name == name {
+			if allImported[idx].Size != size {
+				DeleteDataFiles(name, allImported[idx].Size)
+			}
+			logger.Infof("Replacing previous import of %s from %s", name, allImported[idx].Source)
+			allImported[idx] = imported
+			replaced = true
+		}
+	}
+	if !replaced {
+		allImported = append(allImported, imported)
+	}
+	writeImportedDataSets(allImported)
+	BuildConfigurations()
+	addToCatalog(DataConfigurations[fmt.Sprintf("%s-%d", name, size)])
+
+	fmt.Printf("Imported %s with %d entries, %d same keys and average value size %d\n",
+		name, report.NbEntries, report.NbSameKeys, imported.ValueSize)
+	perf.setNbLines(size)
+	perf.stop()
+	perf.display(fmt.Sprintf("%s imported", name))
+	return nil
+}
+
+func scanTrace(sourceFilename string, lineFunc func(fields []string) error) error {
+	file, err := os.Open(sourceFilename)
+	if err != nil {
+		return err
+	}
+	defer utils.CloseFile(file)
+	tr, err := newTraceReader(file)
+	if err != nil {
+		return err
+	}
+	for {
+		fields, err := tr.next()
+		if err != nil {
+			return err
+		}
+		if fields == nil {
+			return nil
+		}
+		err = lineFunc(fields)
+		if err != nil {
+			return err
+		}
+	}
+}
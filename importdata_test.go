@@ -0,0 +1,55 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func scanTestTrace(t *testing.T, content string) [][]string {
+	filename := filepath.Join(t.TempDir(), "trace.txt")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+	result := make([][]string, 0)
+	assert.NoError(t, scanTrace(filename, func(fields []string) error {
+		result = append(result, fields)
+		return nil
+	}))
+	return result
+}
+
+func TestScanTrace(t *testing.T) {
+	assert.Equal(t, [][]string{{"1", "2", "3", "v1"}, {"4", "5", "6"}},
+		scanTestTrace(t, "1,2,3,v1\n# comment\n\n4,5,6\n"))
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4", "5", "6", "v2"}},
+		scanTestTrace(t, "1;2;3\n4; 5; 6;v2\n"))
+	assert.Equal(t, [][]string{{"alice", "some", "value"}, {"bob"}},
+		scanTestTrace(t, "  alice some value\n# comment\nbob\n"))
+}
+
+func TestParseTraceLine(t *testing.T) {
+	key, value, hasValue, err := parseTraceLine([]string{"1", "-2", "3", "v1"}, ImportInt3dKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, Int3Key{1, -2, 3}, key)
+	assert.Equal(t, "v1", value)
+	assert.True(t, hasValue)
+
+	_, _, hasValue, err = parseTraceLine([]string{"1", "2", "3"}, ImportInt3dKeys)
+	assert.NoError(t, err)
+	assert.False(t, hasValue)
+
+	_, _, _, err = parseTraceLine([]string{"x", "y", "z"}, ImportInt3dKeys)
+	assert.Error(t, err)
+	_, _, _, err = parseTraceLine([]string{"1", "2"}, ImportInt3dKeys)
+	assert.Error(t, err)
+
+	key, value, hasValue, err = parseTraceLine([]string{"alice", "v", "2"}, ImportStringKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, stringToInt3Key("alice"), key)
+	assert.NotEqual(t, stringToInt3Key("bob"), key)
+	assert.Equal(t, "v 2", value)
+	assert.True(t, hasValue)
+	for k := 0; k < 3; k++ {
+		assert.True(t, key[k] >= 0)
+	}
+}
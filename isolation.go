@@ -0,0 +1,303 @@
+package maptester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"github.com/google/logger"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// IsolateTests runs each test in a child runner process, so the heap and the GC debt
+// of a test do not change the measurements of the next one
+var IsolateTests = false
+
+// IsolationTimeout is the maximum duration of a child test process, the test fails after
+var IsolationTimeout = 30 * time.Minute
+
+// The runner command of a child test process, reading the test spec on stdin and
+// writing the CSV line of the result on the file descriptor 3
+const IsolatedTestCommand = "run-test"
+
+// The status of a test in the CSV
+const (
+	StatusOk      = "ok"
+	StatusTimeout = "timeout"
+	StatusCrashed = "crashed"
+)
+
+// isolationCommand creates the child test process command, replaced in tests
+var isolationCommand = func(ctx context.Context) (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, executable, IsolatedTestCommand), nil
+}
+
+// isolatedTestSpec is everything a child process needs to run one test
+type isolatedTestSpec struct {
+	Idx                  int           `json:"idx"`
+	TestName             string        `json:"testName"`
+	DataFileName         string        `json:"dataFileName"`
+	KeyType              string        `json:"keyType"`
+	ConflictRatio        float32       `json:"conflictRatio"`
+	ValueSize            string        `json:"valueSize"`
+	Size                 int           `json:"size"`
+	Imported             bool          `json:"imported"`
+	ReadWriteThreadRatio float32       `json:"readWriteThreadRatio"`
+	ReadWriteNbRatio     int           `json:"readWriteNbRatio"`
+	WorkloadMix          string        `json:"workloadMix"`
+	Scenario             string        `json:"scenario"`
+	GoMaxProcs           int           `json:"goMaxProcs"`
+	GCPercent            int           `json:"gcPercent"`
+	MemoryLimit          int64         `json:"memoryLimit"`
+	NbWriteThreads       int           `json:"nbWriteThreads"`
+	NbReadThreads        int           `json:"nbReadThreads"`
+	NbReadTest           int           `json:"nbReadTest"`
+	InitRatio            float32       `json:"initRatio"`
+	PercentMiss          float32       `json:"percentMiss"`
+	MapTypeName          string        `json:"mapTypeName"`
+	RecordTrace          bool          `json:"recordTrace"`
+	TestDuration         time.Duration `json:"testDuration"`
+	LatencySampleRate    int           `json:"latencySampleRate"`
+	HeapSampleInterval   time.Duration `json:"heapSampleInterval"`
+	WarmupRuns           int           `json:"warmupRuns"`
+	Repetitions          int           `json:"repetitions"`
+	UseFlatData          bool          `json:"useFlatData"`
+	Harness              string        `json:"harness"`
+	Profiles             []string      `json:"profiles"`
+	ProfileDir           string        `json:"profileDir"`
+}
+
+func (mp *MapPerfTestResult) newIsolatedTestSpec(idx int) *isolatedTestSpec {
+	rc := mp.runConf
+	return &isolatedTestSpec{
+		Idx:                  idx,
+		TestName:             mp.Name(),
+		DataFileName:         rc.dataConf.dataFilename,
+		KeyType:              rc.dataConf.keyType,
+		ConflictRatio:        rc.dataConf.conflictRatio,
+		ValueSize:            rc.dataConf.valueSize.Name(),
+		Size:                 rc.dataConf.size,
+		Imported:             rc.dataConf.imported,
+		ReadWriteThreadRatio: rc.readWriteThreadRatio,
+		ReadWriteNbRatio:     rc.readWriteNbRatio,
+		WorkloadMix:          rc.mix.Name(),
+		Scenario:             rc.scenario,
+		GoMaxProcs:           rc.runtime.goMaxProcs,
+		GCPercent:            rc.runtime.gcPercent,
+		MemoryLimit:          rc.runtime.memoryLimit,
+		NbWriteThreads:       rc.testConf.nbWriteThreads,
+		NbReadThreads:        rc.testConf.nbReadThreads,
+		NbReadTest:           rc.testConf.nbReadTest,
+		InitRatio:            rc.testConf.initRatio,
+		PercentMiss:          rc.testConf.percentMiss,
+		MapTypeName:          mp.mapTypeName,
+		RecordTrace:          mp.recordTrace,
+		TestDuration:         TestDuration,
+		LatencySampleRate:    LatencySampleRate,
+		HeapSampleInterval:   HeapSampleInterval,
+		WarmupRuns:           WarmupRuns,
+		Repetitions:          Repetitions,
+		UseFlatData:          UseFlatData,
+		Harness:              Harness,
+		Profiles:             Profiles,
+		ProfileDir:           profileDir,
+	}
+}
+
+// newPerfTest sets the test settings and creates the test of the spec
+func (spec *isolatedTestSpec) newPerfTest() (*MapPerfTestResult, error) {
+	valueSize, err := ParseValueSizeDistribution(spec.ValueSize)
+	if err != nil {
+		return nil, err
+	}
+	mix, err := ParseWorkloadMix(spec.WorkloadMix)
+	if err != nil {
+		return nil, err
+	}
+	if !isMapType(spec.MapTypeName) {
+		return nil, fmt.Errorf("map type %q unknown", spec.MapTypeName)
+	}
+	TestDuration = spec.TestDuration
+	LatencySampleRate = spec.LatencySampleRate
+	HeapSampleInterval = spec.HeapSampleInterval
+	WarmupRuns = spec.WarmupRuns
+	Repetitions = spec.Repetitions
+	UseFlatData = spec.UseFlatData
+	Harness = spec.Harness
+	Profiles = spec.Profiles
+	profileDir = spec.ProfileDir
+
+	dc := &DataConfiguration{
+		dataFilename:  spec.DataFileName,
+		keyType:       spec.KeyType,
+		conflictRatio: spec.ConflictRatio,
+		valueSize:     valueSize,
+		size:          spec.Size,
+		imported:      spec.Imported,
+	}
+	dc.dataSetName = fmt.Sprintf("%s-%d", dc.dataFilename, dc.size)
+	rc := &RunConfiguration{
+		dataConf:             dc,
+		readWriteThreadRatio: spec.ReadWriteThreadRatio,
+		readWriteNbRatio:     spec.ReadWriteNbRatio,
+		mix:                  mix,
+		scenario:             spec.Scenario,
+		runtime: runtimeSettings{
+			goMaxProcs:  spec.GoMaxProcs,
+			gcPercent:   spec.GCPercent,
+			memoryLimit: spec.MemoryLimit,
+		},
+		testConf: &MapTestConf{
+			nbWriteThreads: spec.NbWriteThreads,
+			nbReadThreads:  spec.NbReadThreads,
+			nbReadTest:     spec.NbReadTest,
+			initRatio:      spec.InitRatio,
+			percentMiss:    spec.PercentMiss,
+		},
+	}
+	rc.fillRunName()
+	mp := &MapPerfTestResult{runConf: rc, mapTypeName: spec.MapTypeName, recordTrace: spec.RecordTrace}
+	if mp.Name() != spec.TestName {
+		return nil, fmt.Errorf("test spec of %s creates test %s", spec.TestName, mp.Name())
+	}
+	return mp, nil
+}
+
+// RunIsolatedTest runs in a child process the test of the spec read from in,
+// and writes the CSV line of the result to out
+func RunIsolatedTest(in io.Reader, out io.Writer) bool {
+	spec := new(isolatedTestSpec)
+	err := json.NewDecoder(in).Decode(spec)
+	if err != nil {
+		logger.Errorf("Cannot read the test spec due to %v", err)
+		return false
+	}
+	mp, err := spec.newPerfTest()
+	if err != nil {
+		logger.Errorf("Invalid test spec due to %v", err)
+		return false
+	}
+	dc := mp.runConf.dataConf
+	im, report := ReadIntData(dc.GetDataFileName(), dc.size)
+	if im == nil {
+		logger.Errorf("Cannot run test %s since data set %s cannot be read", mp.Name(), dc.GetDataSetName())
+		return false
+	}
+	defer im.close()
+	mp.fill(report)
+	mp.runRepetitions(im)
+	if mp.trace != nil {
+		mp.saveTrace()
+	}
+	mp.dumpPerfData(spec.Idx, out)
+	return true
+}
+
+// runIsolated runs the test in a child process and writes its result line in the CSV.
+// A child process crashing or timing out is written as a failed test.
+func (mp *MapPerfTestResult) runIsolated(idx int, outFile io.Writer) *PerfLine {
+	mp.stopWatch.init()
+	line, status := mp.runChildProcess(idx)
+	mp.stopWatch.stop()
+	if line == nil {
+		mp.status = status
+		mp.nbMapEntries = 0
+		mp.dumpPerfData(idx, outFile)
+		return nil
+	}
+	_, err := fmt.Fprintln(outFile, line.raw)
+	if err != nil {
+		logger.Fatalf("Cannot write result of %s due to %v", mp.Name(), err)
+	}
+	return line.PerfLine
+}
+
+// childResult is the parsed result line of a child process with the raw line written in the CSV
+type childResult struct {
+	*PerfLine
+	raw string
+}
+
+func (mp *MapPerfTestResult) runChildProcess(idx int) (*childResult, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), IsolationTimeout)
+	defer cancel()
+	cmd, err := isolationCommand(ctx)
+	if err != nil {
+		logger.Errorf("Cannot create the child process of %s due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	spec, err := json.Marshal(mp.newIsolatedTestSpec(idx))
+	if err != nil {
+		logger.Errorf("Cannot write the test spec of %s due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	resultReader, resultWriter, err := os.Pipe()
+	if err != nil {
+		logger.Errorf("Cannot create the result pipe of %s due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	defer resultReader.Close()
+	cmd.Stdin = bytes.NewReader(spec)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{resultWriter}
+	err = cmd.Start()
+	resultWriter.Close()
+	if err != nil {
+		logger.Errorf("Cannot start the child process of %s due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	result, _ := ioutil.ReadAll(resultReader)
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Errorf("Test %s timed out after %v", mp.Name(), IsolationTimeout)
+		return nil, StatusTimeout
+	}
+	if err != nil {
+		logger.Errorf("Test %s child process failed due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	line, err := parseChildResult(result)
+	if err != nil {
+		logger.Errorf("Test %s child process result is invalid due to %v", mp.Name(), err)
+		return nil, StatusCrashed
+	}
+	return line, StatusOk
+}
+
+// parseChildResult reads the CSV line written by a child process
+func parseChildResult(result []byte) (*childResult, error) {
+	raw := string(bytes.TrimSpace(result))
+	if raw == "" {
+		return nil, fmt.Errorf("no result")
+	}
+	var csvData bytes.Buffer
+	writeCsvHeader(&csvData)
+	csvData.WriteString(raw)
+	csvData.WriteString("\n")
+	lines := []*PerfLine{}
+	err := gocsv.UnmarshalBytes(csvData.Bytes(), &lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("expected one result line but got %d", len(lines))
+	}
+	return &childResult{lines[0], raw}, nil
+}
+
+func (mp *MapPerfTestResult) getStatus() string {
+	if mp.status == "" {
+		return StatusOk
+	}
+	return mp.status
+}
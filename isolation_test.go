@@ -0,0 +1,95 @@
+package maptester
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const childModeEnv = "MAPTESTER_CHILD_MODE"
+
+// TestIsolatedChildProcess is the child process of the isolation tests, running on an in memory data set
+func TestIsolatedChildProcess(t *testing.T) {
+	switch os.Getenv(childModeEnv) {
+	case "":
+		t.Skip("only a child process of the isolation tests")
+	case "crash":
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	spec := new(isolatedTestSpec)
+	assert.NoError(t, json.NewDecoder(os.Stdin).Decode(spec))
+	mp, err := spec.newPerfTest()
+	assert.NoError(t, err)
+	im, report := newTestDataSet(spec.Size)
+	mp.fill(report)
+	mp.runRepetitions(im)
+	mp.dumpPerfData(spec.Idx, os.NewFile(3, "result"))
+	if os.Getenv(childModeEnv) == "crash-after-result" {
+		os.Exit(3)
+	}
+}
+
+func setChildMode(t *testing.T, mode string) {
+	isolationCommand = func(ctx context.Context) (*exec.Cmd, error) {
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestIsolatedChildProcess$")
+		cmd.Env = append(os.Environ(), childModeEnv+"="+mode)
+		return cmd, nil
+	}
+}
+
+func TestRunIsolated(t *testing.T) {
+	savedCommand, savedTimeout := isolationCommand, IsolationTimeout
+	defer func() { isolationCommand, IsolationTimeout = savedCommand, savedTimeout }()
+	size := 1000
+	_, report := newTestDataSet(size)
+	newPerfTest := func() *MapPerfTestResult {
+		mp := &MapPerfTestResult{runConf: newTestRunConfiguration(size, 2, 2, 500), mapTypeName: "syncMap"}
+		mp.fill(report)
+		return mp
+	}
+
+	setChildMode(t, "result")
+	mp := newPerfTest()
+	var out bytes.Buffer
+	line := mp.runIsolated(5, &out)
+	if assert.NotNil(t, line) {
+		assert.Equal(t, mp.Name(), line.Name)
+		assert.Equal(t, 0, line.Errors)
+		assert.Equal(t, StatusOk, line.Status)
+		assert.Equal(t, int(report.NbEntries), line.NbMapEntries)
+	}
+	assert.True(t, strings.HasPrefix(out.String(), "5;"+mp.Name()+";"))
+	assert.True(t, mp.wasDone())
+
+	setChildMode(t, "crash")
+	out.Reset()
+	mp = newPerfTest()
+	assert.Nil(t, mp.runIsolated(6, &out))
+	assert.True(t, strings.HasSuffix(out.String(), ";"+StatusCrashed+";;\n"), out.String())
+
+	// A result is not enough, the child process has to exit normally
+	setChildMode(t, "crash-after-result")
+	out.Reset()
+	mp = newPerfTest()
+	assert.Nil(t, mp.runIsolated(8, &out))
+	assert.True(t, strings.HasSuffix(out.String(), ";"+StatusCrashed+";;\n"), out.String())
+
+	setChildMode(t, "hang")
+	IsolationTimeout = 500 * time.Millisecond
+	out.Reset()
+	mp = newPerfTest()
+	assert.Nil(t, mp.runIsolated(7, &out))
+	assert.True(t, strings.HasSuffix(out.String(), ";"+StatusTimeout+";;\n"), out.String())
+
+	failed, err := parseChildResult(out.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, failed.isFailed())
+}
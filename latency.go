@@ -0,0 +1,187 @@
+package maptester
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencySampleRate is the number of operations per latency sample of each thread, 0 disables sampling.
+// The samples add clock reads in the timed loops, so by default only one operation every 64 is sampled.
+var LatencySampleRate = 64
+
+// The percentiles of the latency histograms written in the CSV and displayed
+var LatencyPercentiles = []float64{50.0, 90.0, 99.0, 99.9}
+
+// Log-linear buckets like HDR histograms: values under latencySubCount ns have their own bucket,
+// then each power of 2 is split in latencySubCount buckets, so less than 1/latencySubCount (1.6%) error.
+const (
+	latencySubBits    = 6
+	latencySubCount   = 1 << latencySubBits
+	latencyNbBuckets  = (64 - latencySubBits) * latencySubCount
+	latencyFieldCount = 5
+)
+
+// latencyHistogram counts the sampled operation latencies in nanoseconds.
+// It is filled by one thread, then merged in the test result.
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	max    int64
+}
+
+func latencyBucket(v int64) int {
+	if v < latencySubCount {
+		return int(v)
+	}
+	exp := bits.Len64(uint64(v)) - latencySubBits - 1
+	return (exp+1)*latencySubCount + int(v>>uint(exp)) - latencySubCount
+}
+
+// latencyBucketValue returns the highest value of the bucket
+func latencyBucketValue(bucket int) int64 {
+	if bucket < latencySubCount {
+		return int64(bucket)
+	}
+	exp := uint(bucket/latencySubCount - 1)
+	sub := int64(bucket%latencySubCount + latencySubCount)
+	return (sub+1)<<exp - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	if h.counts == nil {
+		h.counts = make([]int64, latencyNbBuckets)
+	}
+	h.counts[latencyBucket(v)]++
+	h.count++
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// start returns the current time if the operation i is sampled, the zero time otherwise
+func (h *latencyHistogram) start(i int) time.Time {
+	if LatencySampleRate <= 0 || i%LatencySampleRate != 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// end records the latency of the operation if it was sampled
+func (h *latencyHistogram) end(start time.Time) {
+	if !start.IsZero() {
+		h.record(time.Since(start))
+	}
+}
+
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+	if o == nil || o.count == 0 {
+		return
+	}
+	if h.counts == nil {
+		h.counts = make([]int64, latencyNbBuckets)
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.count += o.count
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// percentile returns the latency under which p percent of the samples are
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(p / 100.0 * float64(h.count)))
+	if target < 1 {
+		target = 1
+	}
+	total := int64(0)
+	for bucket, c := range h.counts {
+		total += c
+		if total >= target {
+			v := latencyBucketValue(bucket)
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// fields returns the percentiles then the max in nanoseconds
+func (h *latencyHistogram) fields() []int64 {
+	result := make([]int64, 0, latencyFieldCount)
+	for _, p := range LatencyPercentiles {
+		result = append(result, int64(h.percentile(p)))
+	}
+	return append(result, h.max)
+}
+
+func (h *latencyHistogram) String() string {
+	var sb strings.Builder
+	for _, p := range LatencyPercentiles {
+		sb.WriteString(fmt.Sprintf("p%v=%v ", p, h.percentile(p)))
+	}
+	sb.WriteString(fmt.Sprintf("max=%v (%d samples)", time.Duration(h.max), h.count))
+	return sb.String()
+}
+
+// latencyHeaders returns the CSV columns of the latencies of an operation
+func latencyHeaders(op string) []string {
+	result := make([]string, 0, latencyFieldCount)
+	for _, p := range LatencyPercentiles {
+		result = append(result, fmt.Sprintf("%s p%v", op, p))
+	}
+	return append(result, op+" max")
+}
+
+// opLatencies are the Load and LoadOrStore latencies of a test, merged from all threads
+type opLatencies struct {
+	mutex sync.Mutex
+	load  latencyHistogram
+	store latencyHistogram
+}
+
+func (ol *opLatencies) add(load, store *latencyHistogram) {
+	ol.mutex.Lock()
+	defer ol.mutex.Unlock()
+	ol.load.merge(load)
+	ol.store.merge(store)
+}
+
+func (ol *opLatencies) reset() {
+	ol.mutex.Lock()
+	defer ol.mutex.Unlock()
+	ol.load = latencyHistogram{}
+	ol.store = latencyHistogram{}
+}
+
+// csvFields returns the Load then LoadOrStore latency columns
+func (ol *opLatencies) csvFields() string {
+	var sb strings.Builder
+	for _, v := range append(ol.load.fields(), ol.store.fields()...) {
+		sb.WriteString(fmt.Sprintf("%d%s", v, SEP_CSV))
+	}
+	return sb.String()
+}
+
+func (ol *opLatencies) display() {
+	if ol.load.count > 0 {
+		fmt.Printf("\tLoad latency %v\n", &ol.load)
+	}
+	if ol.store.count > 0 {
+		fmt.Printf("\tLoadOrStore latency %v\n", &ol.store)
+	}
+}
@@ -0,0 +1,43 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	previous := -1
+	for _, v := range []int64{0, 1, 63, 64, 65, 127, 128, 129, 1000, 1e6, 1e9, 1 << 62} {
+		bucket := latencyBucket(v)
+		assert.True(t, bucket >= previous, "bucket of %d", v)
+		assert.True(t, bucket < latencyNbBuckets, "bucket of %d", v)
+		high := latencyBucketValue(bucket)
+		assert.True(t, high >= v, "high value of %d", v)
+		assert.True(t, high == v || high-v < v/latencySubCount, "precision of %d", v)
+		previous = bucket
+	}
+	assert.Equal(t, int64(63), latencyBucketValue(latencyBucket(63)))
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	h := new(latencyHistogram)
+	assert.Equal(t, time.Duration(0), h.percentile(99.0))
+	other := new(latencyHistogram)
+	for i := 1; i <= 1000; i++ {
+		if i%2 == 0 {
+			h.record(time.Duration(i) * time.Microsecond)
+		} else {
+			other.record(time.Duration(i) * time.Microsecond)
+		}
+	}
+	h.merge(other)
+	h.merge(nil)
+	assert.Equal(t, int64(1000), h.count)
+	assert.Equal(t, int64(time.Millisecond), h.max)
+	assert.InDelta(t, float64(500*time.Microsecond), float64(h.percentile(50.0)), float64(10*time.Microsecond))
+	assert.InDelta(t, float64(990*time.Microsecond), float64(h.percentile(99.0)), float64(20*time.Microsecond))
+	assert.Equal(t, time.Millisecond, h.percentile(100.0))
+	assert.Equal(t, []string{"load p50", "load p90", "load p99", "load p99.9", "load max"}, latencyHeaders("load"))
+	assert.Equal(t, latencyFieldCount, len(h.fields()))
+}
@@ -4,28 +4,42 @@ import (
 	"log"
 	"sync"
 	"sync/atomic"
+	"unsafe"
 )
 
 type MapType struct {
 	name              string
 	isConcurrentWrite bool
+	isDeleteSupported bool
 }
 
 var MapTypes = []MapType{
-	{"basic", false},
-	{"RWMutex", true},
-	{"syncMap", true},
-	{"fredMap", true}}
+	{"basic", false, true},
+	{"RWMutex", true, true},
+	{"syncMap", true, true},
+	{"fredMap", true, false}}
+
+// supports returns false if the map type cannot execute the run configuration
+func (mt MapType) supports(rc *RunConfiguration) bool {
+	if !mt.isConcurrentWrite && (rc.testConf.nbWriteThreads > 1 || rc.scenario == InterleavedScenario) {
+		return false
+	}
+	if !mt.isConcurrentWrite && !rc.mix.isPopulate() && rc.mix.hasWrites() && rc.testConf.nbReadThreads > 1 {
+		return false
+	}
+	return mt.isDeleteSupported || !rc.mix.hasDeletes()
+}
 
 type MapKey interface {
 	Hash() int
 	Equal(o MapKey) bool
 }
 
+// TestMapValue is the value stored in the maps. Writers overwrite its value while readers load it,
+// so val and count are accessed atomically once the value is in a map.
 type TestMapValue struct {
-	val         *TestValue
-	count       uint32
-	overwritten bool
+	val   *TestValue
+	count uint32
 }
 
 type ConcurrentInt3Map interface {
@@ -58,15 +72,20 @@ func (mp *MapPerfTestResult) CreateMap() ConcurrentInt3Map {
 TestMapValue Functions
 *********************************************/
 
+// IsOverwritten returns true if the value was replaced at least once
 func (tmv *TestMapValue) IsOverwritten() bool {
-	return tmv.overwritten
+	return atomic.LoadUint32(&tmv.count) > 0
+}
+
+// value returns the current value
+func (tmv *TestMapValue) value() *TestValue {
+	return (*TestValue)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&tmv.val))))
 }
 
 func (tmv *TestMapValue) overwriteVal(newVal *TestValue) {
+	atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&tmv.val)), unsafe.Pointer(newVal))
 	// Add info of overwrite count
-	tmv.overwritten = true
 	atomic.AddUint32(&tmv.count, 1)
-	tmv.val = newVal
 }
 
 /********************************************
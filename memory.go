@@ -0,0 +1,110 @@
+package maptester
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// HeapSampleInterval is the period of the in-use heap samples during a test, 0 disables the sampling.
+// The samples are read from runtime/metrics, which unlike runtime.ReadMemStats does not stop the world.
+var HeapSampleInterval = 10 * time.Millisecond
+
+// The bytes of the heap objects, live or not yet swept, like runtime.MemStats.HeapAlloc
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// heapMeasure is the retained heap of the map after the test, and the peak in-use heap during
+// the test, both above the heap in use before creating the map. Without sampling the peak is
+// the heap in use at the end of the test. The peak includes what the worker threads allocate
+// besides the map, like the latency histograms and the recorded operations.
+type heapMeasure struct {
+	baseline uint64
+	retained uint64
+	peak     uint64
+
+	stopSampling chan struct{}
+	sampling     sync.WaitGroup
+	sampledPeak  uint64
+}
+
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// retainedHeap forces a GC, so only the referenced objects are in use
+func retainedHeap() uint64 {
+	runtime.GC()
+	return heapInUse()
+}
+
+func aboveBaseline(v, baseline uint64) uint64 {
+	if v < baseline {
+		return 0
+	}
+	return v - baseline
+}
+
+// start measures the baseline and starts sampling the heap in use
+func (hm *heapMeasure) start() {
+	hm.baseline = retainedHeap()
+	hm.retained = 0
+	hm.peak = 0
+	hm.sampledPeak = hm.baseline
+	if HeapSampleInterval <= 0 {
+		return
+	}
+	hm.stopSampling = make(chan struct{})
+	hm.sampling.Add(1)
+	go func() {
+		defer hm.sampling.Done()
+		ticker := time.NewTicker(HeapSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hm.stopSampling:
+				return
+			case <-ticker.C:
+				if v := heapInUse(); v > hm.sampledPeak {
+					hm.sampledPeak = v
+				}
+			}
+		}
+	}()
+}
+
+// stop ends the sampling and measures the heap retained by the map, still referenced here
+func (hm *heapMeasure) stop(m ConcurrentInt3Map) {
+	if hm.stopSampling != nil {
+		close(hm.stopSampling)
+		hm.sampling.Wait()
+		hm.stopSampling = nil
+	}
+	if v := heapInUse(); v > hm.sampledPeak {
+		hm.sampledPeak = v
+	}
+	hm.peak = aboveBaseline(hm.sampledPeak, hm.baseline)
+	hm.retained = aboveBaseline(retainedHeap(), hm.baseline)
+	runtime.KeepAlive(m)
+}
+
+func (hm *heapMeasure) bytesPerEntry(nbEntries int) float64 {
+	if nbEntries <= 0 {
+		return 0.0
+	}
+	return float64(hm.retained) / float64(nbEntries)
+}
+
+func (hm *heapMeasure) String() string {
+	return fmt.Sprintf("retained %d KB and peak %d KB heap", hm.retained/1024, hm.peak/1024)
+}
+
+// measureHeap ends the heap measure of the test on the map
+func (mp *MapPerfTestResult) measureHeap(m ConcurrentInt3Map) {
+	mp.heap.stop(m)
+	mp.profileHeap()
+	runtime.KeepAlive(m)
+}
@@ -0,0 +1,27 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+func TestHeapMeasure(t *testing.T) {
+	size := 20000
+	im, _ := newTestDataSet(size)
+	hm := new(heapMeasure)
+	hm.start()
+	m := &BasicConcurrentIntMap{m: make(map[Int3Key]*TestMapValue)}
+	for i := 0; i < size; i++ {
+		m.Store(im.keys[i], &TestMapValue{val: &im.values[i]})
+	}
+	hm.stop(m)
+	// The data set is still in use during real tests
+	runtime.KeepAlive(im)
+	// At least the keys and the value headers
+	assert.True(t, hm.retained > uint64(m.Size()*(Int3KeySize+16)), "%v", hm)
+	assert.True(t, hm.peak >= hm.retained/2, "%v", hm)
+	assert.True(t, hm.bytesPerEntry(m.Size()) > float64(Int3KeySize), "%v", hm)
+	assert.Equal(t, 0.0, hm.bytesPerEntry(0))
+	assert.Equal(t, uint64(0), aboveBaseline(10, 20))
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !linux && !freebsd && !netbsd && !openbsd
+// +build !darwin,!linux,!freebsd,!netbsd,!openbsd
+
+package maptester
+
+import (
+	"io/ioutil"
+)
+
+// Without mmap the flat file is fully read in memory
+func mapFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build darwin || linux || freebsd || netbsd || openbsd
+// +build darwin linux freebsd netbsd openbsd
+
+package maptester
+
+import (
+	"os"
+	"syscall"
+)
+
+// The mapping is read only and shared, so the pages are only read from the page cache
+func mapFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(stat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/logger"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -27,7 +28,13 @@ type PerfLineKey struct {
 	ReadWriteThreadRatio float32 `csv:"r/w threads ratio"`
 	PercentMiss          float32 `csv:"percent miss"`
 	ReadWriteNbRatio     int     `csv:"r/w nb ratio"`
-	ValueSize            int     `csv:"value size"`
+	ValueSize            string  `csv:"value size"`
+	DataSize             int     `csv:"data size"`
+	WorkloadMix          string  `csv:"workload mix"`
+	Scenario             string  `csv:"scenario"`
+	GoMaxProcs           int     `csv:"gomaxprocs"`
+	GCPercent            int     `csv:"gc percent"`
+	MemoryLimit          int64   `csv:"memory limit"`
 	MapTypeName          string  `csv:"map type"`
 	NbLines              int     `csv:"nb lines"`
 	NbMapEntries         int     `csv:"nb map entries"`
@@ -37,10 +44,41 @@ type PerfLineKey struct {
 }
 
 type PerfLineMeasurement struct {
-	ExecDuration int64 `csv:"exec duration"`
-	MemoryUsage  int64 `csv:"memory usage"`
-	GCDone       int   `csv:"GC Done"`
-	Errors       int   `csv:"errors"`
+	Harness         string  `csv:"harness"`
+	Repetitions     int     `csv:"repetitions"`
+	ExecDuration    int64   `csv:"exec duration"`
+	ExecStddev      float64 `csv:"exec duration stddev"`
+	ExecMin         int64   `csv:"exec duration min"`
+	ExecCI95        float64 `csv:"exec duration ci95"`
+	WriteDuration   int64   `csv:"write duration"`
+	ReadDuration    int64   `csv:"read duration"`
+	MixedDuration   int64   `csv:"mixed duration"`
+	TargetDuration  int64   `csv:"target duration"`
+	SetupDuration   int64   `csv:"setup duration"`
+	StartSkew       int64   `csv:"start skew"`
+	WriteFinishSkew int64   `csv:"write finish skew"`
+	ReadFinishSkew  int64   `csv:"read finish skew"`
+	WriteThroughput float64 `csv:"write throughput"`
+	ReadThroughput  float64 `csv:"read throughput"`
+	MixedThroughput float64 `csv:"mixed throughput"`
+	LoadP50         int64   `csv:"load p50"`
+	LoadP90         int64   `csv:"load p90"`
+	LoadP99         int64   `csv:"load p99"`
+	LoadP999        int64   `csv:"load p99.9"`
+	LoadMax         int64   `csv:"load max"`
+	StoreP50        int64   `csv:"store p50"`
+	StoreP90        int64   `csv:"store p90"`
+	StoreP99        int64   `csv:"store p99"`
+	StoreP999       int64   `csv:"store p99.9"`
+	StoreMax        int64   `csv:"store max"`
+	MemoryUsage     int64   `csv:"memory usage"`
+	RetainedHeap    int64   `csv:"retained heap"`
+	BytesPerEntry   float64 `csv:"bytes per entry"`
+	PeakHeap        int64   `csv:"peak heap"`
+	GCDone          int     `csv:"GC Done"`
+	Errors          int     `csv:"errors"`
+	Status          string  `csv:"status"`
+	Profiles        string  `csv:"profiles"`
 }
 
 type PerfLine struct {
@@ -54,6 +92,10 @@ type AggregateMeasurement struct {
 	totalLines      int64
 	totalMapEntries int64
 	totalReadDone   int64
+	// Sum of the squared confidence intervals, assuming independent measurements
+	execCI95Squares float64
+	// The map entries of the measurements with a retained heap
+	retainedMapEntries int64
 	PerfLineMeasurement
 }
 
@@ -65,13 +107,23 @@ func init() {
 	})
 }
 
+// isFailed returns true if the test did not complete, files without status have only completed tests
+func (line *PerfLine) isFailed() bool {
+	return line.Status != "" && line.Status != StatusOk
+}
+
 func (agg *AggregateMeasurement) addMeasurement(line PerfLine) {
 	agg.count++
 	agg.totalLines += int64(line.NbLines)
 	agg.totalMapEntries += int64(line.NbMapEntries)
 	agg.totalReadDone += int64(line.NbReadTest)
 	agg.ExecDuration += line.ExecDuration
+	agg.execCI95Squares += line.ExecCI95 * line.ExecCI95
 	agg.MemoryUsage += line.MemoryUsage
+	if line.RetainedHeap > 0 {
+		agg.RetainedHeap += line.RetainedHeap
+		agg.retainedMapEntries += int64(line.NbMapEntries)
+	}
 	agg.GCDone += line.GCDone
 	agg.Errors += line.Errors
 }
@@ -80,21 +132,32 @@ func (agg *AggregateMeasurement) avgExec() float32 {
 	return float32(agg.ExecDuration) / float32(agg.totalReadDone+agg.totalLines)
 }
 
+// avgExecCI95 is the half width of the 95% confidence interval of avgExec,
+// propagated from the confidence intervals of the measurements
+func (agg *AggregateMeasurement) avgExecCI95() float32 {
+	return float32(math.Sqrt(agg.execCI95Squares)) / float32(agg.totalReadDone+agg.totalLines)
+}
+
+// avgMem is the retained heap per map entry, or the allocated bytes per entry for files without retained heap
 func (agg *AggregateMeasurement) avgMem() float32 {
+	if agg.retainedMapEntries > 0 {
+		return float32(agg.RetainedHeap) / float32(agg.retainedMapEntries)
+	}
 	return float32(agg.MemoryUsage) / float32(agg.totalMapEntries)
 }
 
 func (agg *AggregateMeasurement) display() {
-	fmt.Println(agg.count, agg.avgExec(), agg.avgMem())
+	fmt.Println(agg.count, agg.avgExec(), agg.avgExecCI95(), agg.avgMem())
 }
 
 const (
 	Float32Multiplier = 1000
-	NbAggregatorMaps  = 4
+	NbAggregatorMaps  = 5
 	InitRatioMap      = 0
 	ConflictRatioMap  = 1
 	NbWriteThreadsMap = 2
 	NbReadThreadsMap  = 3
+	DataSizeMap       = 4
 )
 
 func (line *PerfLine) getMapKey(mapIdx int) int {
@@ -107,6 +170,12 @@ func (line *PerfLine) getMapKey(mapIdx int) int {
 		return line.NbWriteThreads
 	case NbReadThreadsMap:
 		return line.NbReadThreads
+	case DataSizeMap:
+		if line.DataSize == 0 {
+			// Files generated before data size was a dimension
+			return line.NbLines
+		}
+		return line.DataSize
 	default:
 		log.Fatalf("map index %d not supported", mapIdx)
 	}
@@ -123,6 +192,8 @@ func getDisplayName(mapIdx int) string {
 		return "nb write threads"
 	case NbReadThreadsMap:
 		return "nb read threads"
+	case DataSizeMap:
+		return "data size"
 	default:
 		log.Fatalf("map index %d not supported", mapIdx)
 	}
@@ -139,6 +210,8 @@ func getDisplayFromKey(mapIdx int, key int) string {
 		return fmt.Sprintf("%d", key)
 	case NbReadThreadsMap:
 		return fmt.Sprintf("%d", key)
+	case DataSizeMap:
+		return fmt.Sprintf("%d", key)
 	default:
 		log.Fatalf("map index %d not supported", mapIdx)
 	}
@@ -220,9 +293,10 @@ func AnalyzePerfFiles(fileNames []string) {
 	}
 	utils.WriteNextString(outFile, "\n")
 
-	utils.WriteNextString(outFile, "map type,total,avg exec,avg mem\n")
+	utils.WriteNextString(outFile, "map type,total,avg exec,avg exec ci95,avg mem\n")
 	for _, agg := range aggregators {
-		utils.WriteNextString(outFile, fmt.Sprintf("%s,total,%f,%f\n", agg.mapType, agg.total.avgExec(), agg.total.avgMem()))
+		utils.WriteNextString(outFile, fmt.Sprintf("%s,total,%f,%f,%f\n", agg.mapType,
+			agg.total.avgExec(), agg.total.avgExecCI95(), agg.total.avgMem()))
 	}
 	utils.WriteNextString(outFile, "\n")
 
@@ -252,6 +326,16 @@ func AnalyzePerfFiles(fileNames []string) {
 				}
 			}
 			utils.WriteNextString(outFile, "\n")
+			utils.WriteNextString(outFile, fmt.Sprintf("%s ci95", agg.mapType))
+			for _, k := range keys {
+				val, ok := agg.maps[idx][k]
+				if ok {
+					utils.WriteNextString(outFile, fmt.Sprintf(",%f", val.avgExecCI95()))
+				} else {
+					utils.WriteNextString(outFile, ",")
+				}
+			}
+			utils.WriteNextString(outFile, "\n")
 		}
 		utils.WriteNextString(outFile, "\n")
 
@@ -274,6 +358,9 @@ func addFileMeasurements(file string, aggregators [4]*Aggregator) {
 	}
 	defer utils.CloseFile(perfFile)
 	err = gocsv.UnmarshalToCallback(perfFile, func(line PerfLine) {
+		if line.isFailed() {
+			return
+		}
 		aggregators[0].addMeasurement(line)
 		aggregators[1].addMeasurement(line)
 		aggregators[2].addMeasurement(line)
@@ -0,0 +1,137 @@
+package maptester
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+)
+
+const (
+	CpuProfile   = "cpu"
+	HeapProfile  = "heap"
+	MutexProfile = "mutex"
+	BlockProfile = "block"
+	TraceProfile = "trace"
+)
+
+var ProfileKinds = []string{CpuProfile, HeapProfile, MutexProfile, BlockProfile, TraceProfile}
+
+// Profiles are the profiles captured during an extra run of each test, before the measured runs and not measured.
+// Mutex and block profiles accumulate in a process, use the isolation mode for per test profiles.
+var Profiles []string
+
+// The directory of the profiles of the tests of a results file, build/perf/<results file name>
+var profileDir = ""
+
+// SelectProfiles sets the profiles from a comma separated list of profile kinds
+func SelectProfiles(value string) error {
+	profiles := strings.Split(value, ",")
+	for _, profile := range profiles {
+		if !containsString(ProfileKinds, profile) {
+			return fmt.Errorf("profile %q unknown, expected one of %s", profile, strings.Join(ProfileKinds, ","))
+		}
+	}
+	Profiles = profiles
+	return nil
+}
+
+func setProfileDir(resultFilename string) {
+	profileDir = strings.TrimSuffix(resultFilename, ".csv")
+}
+
+func isProfiling(profile string) bool {
+	return containsString(Profiles, profile)
+}
+
+func (mp *MapPerfTestResult) getProfileFilename(profile string) string {
+	ext := ".pprof"
+	if profile == TraceProfile {
+		ext = ".out"
+	}
+	return filepath.Join(profileDir, fmt.Sprintf("%s.%s%s", mp.Name(), profile, ext))
+}
+
+// createProfileFile creates the file of the profile and adds it to the test profile files
+func (mp *MapPerfTestResult) createProfileFile(profile string) *os.File {
+	filename := mp.getProfileFilename(profile)
+	err := os.MkdirAll(profileDir, os.ModePerm)
+	if err != nil {
+		logger.Errorf("Cannot create profile directory %s due to %v", profileDir, err)
+		return nil
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		logger.Errorf("Cannot create profile file %s due to %v", filename, err)
+		return nil
+	}
+	mp.profileFiles = append(mp.profileFiles, filepath.Join(filepath.Base(profileDir), filepath.Base(filename)))
+	return file
+}
+
+func (mp *MapPerfTestResult) writeProfile(profile string) {
+	file := mp.createProfileFile(profile)
+	if file == nil {
+		return
+	}
+	defer file.Close()
+	err := pprof.Lookup(profile).WriteTo(file, 0)
+	if err != nil {
+		logger.Errorf("Cannot write %s profile of %s due to %v", profile, mp.Name(), err)
+	}
+}
+
+// testConcurrentMapProfiled runs the test capturing the selected profiles
+func (mp *MapPerfTestResult) testConcurrentMapProfiled(im *IntMapTestDataSet) {
+	mp.profileFiles = nil
+	mp.profiling = true
+	defer func() { mp.profiling = false }()
+	if isProfiling(CpuProfile) {
+		if file := mp.createProfileFile(CpuProfile); file != nil {
+			defer file.Close()
+			if err := pprof.StartCPUProfile(file); err != nil {
+				logger.Errorf("Cannot start CPU profile of %s due to %v", mp.Name(), err)
+			} else {
+				defer pprof.StopCPUProfile()
+			}
+		}
+	}
+	if isProfiling(TraceProfile) {
+		if file := mp.createProfileFile(TraceProfile); file != nil {
+			defer file.Close()
+			if err := trace.Start(file); err != nil {
+				logger.Errorf("Cannot start execution trace of %s due to %v", mp.Name(), err)
+			} else {
+				defer trace.Stop()
+			}
+		}
+	}
+	if isProfiling(MutexProfile) {
+		runtime.SetMutexProfileFraction(1)
+		defer runtime.SetMutexProfileFraction(0)
+	}
+	if isProfiling(BlockProfile) {
+		runtime.SetBlockProfileRate(1)
+		defer runtime.SetBlockProfileRate(0)
+	}
+
+	mp.testConcurrentMap(im)
+
+	if isProfiling(MutexProfile) {
+		mp.writeProfile(MutexProfile)
+	}
+	if isProfiling(BlockProfile) {
+		mp.writeProfile(BlockProfile)
+	}
+}
+
+// profileHeap writes the heap profile while the map is still referenced
+func (mp *MapPerfTestResult) profileHeap() {
+	if mp.profiling && isProfiling(HeapProfile) {
+		mp.writeProfile(HeapProfile)
+	}
+}
@@ -0,0 +1,38 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	defer func(profiles []string, dir string) { Profiles, profileDir = profiles, dir }(Profiles, profileDir)
+	defer func(w, r int) { WarmupRuns, Repetitions = w, r }(WarmupRuns, Repetitions)
+	WarmupRuns = 0
+	Repetitions = 2
+	assert.Error(t, SelectProfiles("cpu,memory"))
+	assert.NoError(t, SelectProfiles("cpu,heap,mutex,block,trace"))
+	dir := t.TempDir()
+	setProfileDir(filepath.Join(dir, "maptests-001.csv"))
+
+	size := 1000
+	im, report := newTestDataSet(size)
+	mp := &MapPerfTestResult{runConf: newTestRunConfiguration(size, 2, 2, 500), mapTypeName: "RWMutex"}
+	mp.fill(report)
+	mp.runRepetitions(im)
+	assert.Equal(t, 0, mp.totalErrors())
+	// The profiled run is an extra run not in the exec stats
+	assert.Equal(t, 3, mp.nbRuns)
+	assert.Equal(t, 2, mp.execStats.count())
+	assert.Equal(t, len(ProfileKinds), len(mp.profileFiles))
+	for _, profile := range ProfileKinds {
+		filename := mp.getProfileFilename(profile)
+		assert.Contains(t, mp.profileFiles, filepath.Join("maptests-001", filepath.Base(filename)))
+		info, err := os.Stat(filename)
+		if assert.NoError(t, err, profile) {
+			assert.True(t, info.Size() > 0, profile)
+		}
+	}
+}
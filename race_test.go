@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package maptester
+
+func init() {
+	raceDetector = true
+}
@@ -0,0 +1,137 @@
+package maptester
+
+import (
+	"fmt"
+	"math"
+)
+
+// WarmupRuns is the number of runs of each test before the measured ones, their results are dropped
+var WarmupRuns = 0
+
+// Repetitions is the number of measured runs of each test
+var Repetitions = 1
+
+// Two sided 95% critical values of the Student t distribution for 1 to 30 degrees of freedom
+var studentT95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+const normal95 = 1.96
+
+// sampleStats are the statistics of a measurement over the repetitions of a test
+type sampleStats struct {
+	samples []float64
+}
+
+func (s *sampleStats) add(v float64) {
+	s.samples = append(s.samples, v)
+}
+
+func (s *sampleStats) count() int {
+	return len(s.samples)
+}
+
+func (s *sampleStats) mean() float64 {
+	if len(s.samples) == 0 {
+		return 0.0
+	}
+	total := 0.0
+	for _, v := range s.samples {
+		total += v
+	}
+	return total / float64(len(s.samples))
+}
+
+// stddev is the sample standard deviation, 0 with less than 2 samples
+func (s *sampleStats) stddev() float64 {
+	n := len(s.samples)
+	if n < 2 {
+		return 0.0
+	}
+	mean := s.mean()
+	total := 0.0
+	for _, v := range s.samples {
+		total += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(total / float64(n-1))
+}
+
+func (s *sampleStats) min() float64 {
+	if len(s.samples) == 0 {
+		return 0.0
+	}
+	result := s.samples[0]
+	for _, v := range s.samples[1:] {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+// ci95 is the half width of the 95% confidence interval of the mean, 0 with less than 2 samples
+func (s *sampleStats) ci95() float64 {
+	n := len(s.samples)
+	if n < 2 {
+		return 0.0
+	}
+	t := normal95
+	if n-1 <= len(studentT95) {
+		t = studentT95[n-2]
+	}
+	return t * s.stddev() / math.Sqrt(float64(n))
+}
+
+func (s *sampleStats) String() string {
+	return fmt.Sprintf("mean=%.0f stddev=%.0f min=%.0f ci95=±%.0f over %d runs",
+		s.mean(), s.stddev(), s.min(), s.ci95(), s.count())
+}
+
+// runRepetitions runs the warmup runs, the profiled run if profiling, then the measured repetitions of the test.
+// The profiled run is not measured since profiling slows it down.
+// The trace is recorded on the last repetition, and the other measurements are the ones of the last repetition.
+func (mp *MapPerfTestResult) runRepetitions(im *IntMapTestDataSet) {
+	// The runtime settings of the run configuration apply to all the runs
+	defer mp.runConf.runtime.apply()()
+	mp.execStats = sampleStats{}
+	mp.previousRunsErrors = 0
+	mp.nbRuns = 0
+	recordTrace := mp.recordTrace
+	mp.recordTrace = false
+	for i := 0; i < WarmupRuns; i++ {
+		mp.testConcurrentMap(im)
+		mp.previousRunsErrors += mp.NbErrors()
+		mp.nbRuns++
+	}
+	if len(Profiles) > 0 {
+		mp.testConcurrentMapProfiled(im)
+		mp.previousRunsErrors += mp.NbErrors()
+		mp.nbRuns++
+	}
+	for i := 0; i < Repetitions; i++ {
+		if i > 0 {
+			mp.previousRunsErrors += mp.NbErrors()
+		}
+		mp.recordTrace = recordTrace && i == Repetitions-1
+		mp.testConcurrentMap(im)
+		mp.execStats.add(float64(mp.execDuration().Microseconds()))
+		mp.nbRuns++
+	}
+	if mp.execStats.count() > 1 {
+		fmt.Printf("%s exec duration in µs %v\n", mp.Name(), &mp.execStats)
+	}
+}
+
+// totalErrors are the errors of all the runs of the test, including warmup
+func (mp *MapPerfTestResult) totalErrors() int {
+	return mp.previousRunsErrors + mp.NbErrors()
+}
+
+// execDurationStats returns the number of repetitions, and the mean, standard deviation, min and
+// confidence interval of the exec duration in µs, or the exec duration of the single run
+func (mp *MapPerfTestResult) execDurationStats() (int, float64, float64, float64, float64) {
+	if mp.execStats.count() == 0 {
+		d := float64(mp.execDuration().Microseconds())
+		return 1, d, 0.0, d, 0.0
+	}
+	s := &mp.execStats
+	return s.count(), s.mean(), s.stddev(), s.min(), s.ci95()
+}
@@ -0,0 +1,51 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSampleStats(t *testing.T) {
+	s := new(sampleStats)
+	assert.Equal(t, 0.0, s.mean())
+	assert.Equal(t, 0.0, s.ci95())
+	s.add(10.0)
+	assert.Equal(t, 0.0, s.stddev())
+	assert.Equal(t, 0.0, s.ci95())
+	for _, v := range []float64{12.0, 14.0, 16.0} {
+		s.add(v)
+	}
+	assert.Equal(t, 4, s.count())
+	assert.Equal(t, 13.0, s.mean())
+	assert.InDelta(t, 2.582, s.stddev(), 0.001)
+	assert.Equal(t, 10.0, s.min())
+	assert.InDelta(t, 3.182*2.582/2.0, s.ci95(), 0.001)
+}
+
+func TestRunRepetitions(t *testing.T) {
+	defer func(w, r int) { WarmupRuns, Repetitions = w, r }(WarmupRuns, Repetitions)
+	WarmupRuns = 1
+	Repetitions = 3
+	size := 1001
+	im, report := newTestDataSet(size)
+	rc := newTestRunConfiguration(size, 2, 2, 500)
+	mp := &MapPerfTestResult{runConf: rc, mapTypeName: "RWMutex"}
+	mp.fill(report)
+	mp.runRepetitions(im)
+	assert.Equal(t, 0, mp.totalErrors())
+	repetitions, mean, _, min, _ := mp.execDurationStats()
+	assert.Equal(t, 3, repetitions)
+	assert.True(t, min > 0 && min <= mean)
+
+	agg := new(AggregateMeasurement)
+	for _, ci := range []float64{30.0, 40.0} {
+		line := PerfLine{}
+		line.NbLines = 50
+		line.NbReadTest = 50
+		line.ExecDuration = 1000
+		line.ExecCI95 = ci
+		agg.addMeasurement(line)
+	}
+	assert.InDelta(t, 10.0, agg.avgExec(), 0.001)
+	assert.InDelta(t, 50.0/200.0, agg.avgExecCI95(), 0.001)
+}
@@ -0,0 +1,107 @@
+package maptester
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/google/logger"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// ResumeFile is the results CSV of an interrupted test run to complete
+var ResumeFile = ""
+
+// getPlanFilename returns the file listing the tests planned for a results CSV
+func getPlanFilename(resultFilename string) string {
+	return strings.TrimSuffix(resultFilename, ".csv") + "-plan.txt"
+}
+
+// writeTestPlan saves the names of the tests to run, one per line
+func writeTestPlan(resultFilename string, perfTests []*MapPerfTestResult) {
+	var plan bytes.Buffer
+	for _, perfTest := range perfTests {
+		plan.WriteString(perfTest.Name())
+		plan.WriteString("\n")
+	}
+	utils.ExitOnError(ioutil.WriteFile(getPlanFilename(resultFilename), plan.Bytes(), 0644))
+}
+
+// readTestPlan returns the tests planned for a results CSV. The experiment config saved with
+// the results is loaded first, the data sizes and other dimension flags should be the same.
+func readTestPlan(resultFilename string) ([]*MapPerfTestResult, error) {
+	configFilename := getExperimentConfigFilename(resultFilename)
+	if ExperimentConfigContent == nil && utils.FileExists(configFilename) {
+		fmt.Println("Loading the experiment config", configFilename)
+		err := LoadExperimentConfig(configFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := ioutil.ReadFile(getPlanFilename(resultFilename))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*MapPerfTestResult, 0)
+	for _, name := range strings.Fields(string(data)) {
+		perfTest, err := newPerfTestFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, perfTest)
+	}
+	return result, nil
+}
+
+// newPerfTestFromName finds the run configuration and the map type of a test name
+func newPerfTestFromName(name string) (*MapPerfTestResult, error) {
+	for _, mt := range MapTypes {
+		runName := strings.TrimSuffix(name, "-"+mt.name)
+		if runName == name {
+			continue
+		}
+		rc, ok := RunConfigurations[runName]
+		if !ok {
+			return nil, fmt.Errorf("run configuration %s of test %s not found, check the dimension flags", runName, name)
+		}
+		return &MapPerfTestResult{runConf: rc, mapTypeName: mt.name}, nil
+	}
+	return nil, fmt.Errorf("map type of test %s unknown", name)
+}
+
+// resumeKey returns the key without the measured number of entries and reads,
+// and with the ratios rounded like in the CSV
+func (key PerfLineKey) resumeKey() PerfLineKey {
+	round := func(f float32) float32 {
+		return float32(math.Round(float64(f)*1e6) / 1e6)
+	}
+	key.NbMapEntries = 0
+	key.NbReadTest = 0
+	key.InitRatio = round(key.InitRatio)
+	key.ConflictRatio = round(key.ConflictRatio)
+	key.ReadWriteThreadRatio = round(key.ReadWriteThreadRatio)
+	key.PercentMiss = round(key.PercentMiss)
+	return key
+}
+
+// openCsvFileForResume removes the last line if it was not completely written,
+// and opens the results CSV to append the next results after the returned number of results
+func openCsvFileForResume(resultFilename string) (*os.File, int) {
+	data, err := ioutil.ReadFile(resultFilename)
+	utils.ExitOnError(err)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = data[:bytes.LastIndexByte(data, '\n')+1]
+		logger.Warningf("Removing the incomplete last line of %s", resultFilename)
+		utils.ExitOnError(os.Truncate(resultFilename, int64(len(data))))
+	}
+	// Without the header
+	nbResults := bytes.Count(data, []byte("\n")) - 1
+	outFile, err := os.OpenFile(resultFilename, os.O_WRONLY|os.O_APPEND, 0665)
+	if err != nil {
+		logger.Fatalf("cannot open perf out file %q due to %v", resultFilename, err)
+	}
+	setProfileDir(resultFilename)
+	return outFile, nbResults
+}
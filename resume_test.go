@@ -0,0 +1,70 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumePerfLines(t *testing.T) {
+	size := 1000
+	im, report := newTestDataSet(size)
+	perfTests := make([]*MapPerfTestResult, 0, 2)
+	for _, mapTypeName := range []string{"RWMutex", "syncMap"} {
+		rc := newTestRunConfiguration(size, 3, 2, 500)
+		rc.readWriteThreadRatio = float32(2) / float32(3)
+		mp := &MapPerfTestResult{runConf: rc, mapTypeName: mapTypeName}
+		mp.fill(report)
+		mp.testConcurrentMap(im)
+		perfTests = append(perfTests, mp)
+	}
+
+	filename := filepath.Join(t.TempDir(), "perf.csv")
+	outFile, err := os.Create(filename)
+	assert.NoError(t, err)
+	writeCsvHeader(outFile)
+	perfTests[0].dumpPerfData(0, outFile)
+	perfTests[1].dumpPerfData(1, outFile)
+	// Interrupted while writing
+	_, err = outFile.WriteString("2;int3d-c50")
+	assert.NoError(t, err)
+	assert.NoError(t, outFile.Close())
+
+	done := readAllPerfLines(filename)
+	assert.Equal(t, 2, len(done))
+	for _, mp := range perfTests {
+		measurement, ok := done[mp.extractPerfLineKey().resumeKey()]
+		assert.True(t, ok, mp.Name())
+		assert.Equal(t, mp.execDuration().Microseconds(), measurement.ExecDuration)
+	}
+
+	outFile, nbResults := openCsvFileForResume(filename)
+	assert.Equal(t, 2, nbResults)
+	perfTests[0].dumpPerfData(2, outFile)
+	assert.NoError(t, outFile.Close())
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "2;int3d-c50\n")
+	assert.Equal(t, 2, len(readAllPerfLines(filename)))
+}
+
+func TestTestPlan(t *testing.T) {
+	perfTests := getAllRunnableTests()
+	assert.True(t, len(perfTests) > 2)
+	perfTests = perfTests[:3]
+	filename := filepath.Join(t.TempDir(), "perf.csv")
+	writeTestPlan(filename, perfTests)
+	planned, err := readTestPlan(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, len(perfTests), len(planned))
+	for i, mp := range planned {
+		assert.Equal(t, perfTests[i].Name(), mp.Name())
+		assert.Equal(t, perfTests[i].runConf, mp.runConf)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(getPlanFilename(filename), []byte("int3d-c50-unknown-RWMutex\n"), 0644))
+	_, err = readTestPlan(filename)
+	assert.Error(t, err)
+}
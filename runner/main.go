@@ -1,10 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/freddy33/maptester"
+	"github.com/freddy33/maptester/utils"
+	"io/ioutil"
 	"os"
-	"runtime"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 func main() {
@@ -12,18 +17,49 @@ func main() {
 	if len(os.Args) > 1 {
 		c = os.Args[1]
 	}
+	if c != "help" {
+		// The imported data sets are data configurations of all the other commands
+		maptester.LoadImportedDataSets()
+	}
 	switch c {
 	case "help":
 		usage()
 	case "show":
+		parseSizeFlags(c)
 		maptester.DisplayConfigurations()
+		maptester.DisplayCatalog()
 	case "clean":
+		parseSizeFlags(c)
 		maptester.DeleteAllData()
 	case "regen":
+		parseGenFlags(c)
 		maptester.DeleteAllData()
 		maptester.GenAllData()
 	case "gen":
+		parseGenFlags(c)
 		maptester.GenAllData()
+	case "convert":
+		parseSizeFlags(c)
+		if !maptester.ConvertAllData() {
+			os.Exit(3)
+		}
+	case "import":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(2)
+		}
+		source := os.Args[2]
+		flags := flag.NewFlagSet(c, flag.ExitOnError)
+		defaultName := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		name := flags.String("name", defaultName, "name of the imported data configuration")
+		keyType := flags.String("key", maptester.ImportInt3dKeys, "key type of the source file: int3d or string")
+		seed := flags.Int64("seed", maptester.GenSeed, "seed for the values missing in the source file")
+		parseFlags(flags, os.Args[3:])
+		err := maptester.ImportTrace(source, *name, *keyType, *seed)
+		if err != nil {
+			fmt.Printf("Import of %s failed due to %v\n", source, err)
+			os.Exit(3)
+		}
 	case "analyze":
 		if len(os.Args) < 3 {
 			usage()
@@ -34,22 +70,101 @@ func main() {
 			filenames[idx] = os.Args[idx+2]
 		}
 		maptester.AnalyzePerfFiles(filenames)
+	case "overhead":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(2)
+		}
+		maptester.CompareHarness(os.Args[2:])
 	case "read":
 		if len(os.Args) < 3 {
 			usage()
 			os.Exit(2)
 		}
 		name := os.Args[2]
-		im, res := maptester.ReadIntData(name, maptester.GenDataSize)
-		goodData := maptester.Verify(name, im, res)
-		if !goodData {
+		size := maptester.DefaultDataSize
+		if dc, ok := maptester.DataConfigurations[name]; ok {
+			name = dc.GetDataFileName()
+			size = dc.GetSize()
+		} else if idx := strings.LastIndex(name, "-"); idx > 0 {
+			// Data set name of a size not in the default data sizes
+			if dataSize, err := strconv.Atoi(name[idx+1:]); err == nil {
+				name = name[:idx]
+				size = dataSize
+			}
+		}
+		flags := flag.NewFlagSet(c, flag.ExitOnError)
+		flags.IntVar(&size, "size", size, "number of lines of the data set")
+		out := flags.String("out", "", "file to write the JSON verification report to")
+		parseFlags(flags, os.Args[3:])
+		// The reading progress goes to stderr, so stdout is only the JSON report
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		im, res := maptester.ReadIntData(name, size)
+		verification := maptester.Verify(name, size, im, res)
+		os.Stdout = stdout
+		fmt.Println(verification.Json())
+		if *out != "" {
+			utils.ExitOnError(ioutil.WriteFile(*out, []byte(verification.Json()+"\n"), 0644))
+		}
+		if !verification.Pass {
 			os.Exit(3)
 		}
+	case "trace":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(2)
+		}
+		flags := flag.NewFlagSet(c, flag.ExitOnError)
+		addDimensionFlags(flags)
+		seed := flags.Int64("seed", 1, "seed of the generated operations")
+		out := flags.String("out", "", "trace file, default in build/traces")
+		parseFlags(flags, os.Args[3:])
+		err := maptester.GenerateTraceFile(os.Args[2], *seed, *out)
+		if err != nil {
+			fmt.Printf("Trace generation failed due to %v\n", err)
+			os.Exit(3)
+		}
+	case "replay":
+		if len(os.Args) < 3 {
+			usage()
+			os.Exit(2)
+		}
+		flags := flag.NewFlagSet(c, flag.ExitOnError)
+		addDimensionFlags(flags)
+		maps := flags.String("map", "", "comma separated map types to replay on, default all")
+		addRepetitionFlags(flags)
+		parseFlags(flags, os.Args[3:])
+		var mapTypeNames []string
+		if *maps != "" {
+			mapTypeNames = strings.Split(*maps, ",")
+		}
+		if !maptester.ReplayTrace(os.Args[2], mapTypeNames) {
+			os.Exit(4)
+		}
 	case "test":
-		runtime.GOMAXPROCS(maptester.MaxConThreads * 2)
+		flags := flag.NewFlagSet(c, flag.ExitOnError)
+		addDimensionFlags(flags)
+		flags.BoolVar(&maptester.RecordTraces, "record", maptester.RecordTraces, "record the operations of each test in build/traces")
+		addRepetitionFlags(flags)
+		addFilterFlags(flags)
+		flags.BoolVar(&maptester.IsolateTests, "isolate", maptester.IsolateTests, "run each test in a child process")
+		flags.DurationVar(&maptester.IsolationTimeout, "timeout", maptester.IsolationTimeout, "maximum duration of a test child process")
+		flags.StringVar(&maptester.ResumeFile, "resume", maptester.ResumeFile, "results CSV of an interrupted test run to complete with the same plan")
+		flags.Var(new(harnessFlag), "harness", "keys of the read threads: precomputed, local-rand or shared-rand")
+		flags.Var(new(profileFlag), "profile", "comma separated profiles of each test: cpu, heap, mutex, block, trace")
+		flags.DurationVar(&maptester.HeapSampleInterval, "heap-sample", maptester.HeapSampleInterval, "period of the in-use heap samples for the peak heap, 0 to disable")
+		flags.IntVar(&maptester.LatencySampleRate, "latency-sample", maptester.LatencySampleRate, "measure the latency of one operation every this number per thread, 0 to disable")
+		flags.DurationVar(&maptester.TestDuration, "duration", maptester.TestDuration, "run each test for this duration on a populated map, like 10s")
+		parseFlags(flags, os.Args[2:])
 		if !maptester.TestAll() {
 			os.Exit(4)
 		}
+	case maptester.IsolatedTestCommand:
+		// Child process of an isolated test, the result is written on the file descriptor 3
+		if !maptester.RunIsolatedTest(os.Stdin, os.NewFile(3, "result")) {
+			os.Exit(4)
+		}
 	default:
 		fmt.Printf("Command %q unknown\n", c)
 		usage()
@@ -57,7 +172,283 @@ func main() {
 	}
 }
 
+// sizesFlag selects the data sizes dimension from a comma separated list like 10K,100K,1M
+type sizesFlag struct{}
+
+func (s *sizesFlag) String() string {
+	return fmt.Sprint(maptester.DataSizes)
+}
+
+func (s *sizesFlag) Set(value string) error {
+	sizes, err := utils.ParseSizes(value)
+	if err != nil {
+		return err
+	}
+	maptester.SelectDataSizes(sizes)
+	return nil
+}
+
+// valuesFlag selects the value size distributions dimension from a comma separated list like v12,vu16-1024
+type valuesFlag struct{}
+
+func (v *valuesFlag) String() string {
+	return fmt.Sprint(maptester.ValueSizes)
+}
+
+func (v *valuesFlag) Set(value string) error {
+	names := strings.Split(value, ",")
+	valueSizes := make([]maptester.ValueSizeDistribution, len(names))
+	for i, name := range names {
+		d, err := maptester.ParseValueSizeDistribution(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		valueSizes[i] = d
+	}
+	maptester.SelectValueSizes(valueSizes)
+	return nil
+}
+
+// mixesFlag selects the workload mixes dimension from a comma separated list like populate,ycsbA,r90i5d5-latest
+type mixesFlag struct{}
+
+func (mf *mixesFlag) String() string {
+	return fmt.Sprint(maptester.WorkloadMixes)
+}
+
+func (mf *mixesFlag) Set(value string) error {
+	names := strings.Split(value, ",")
+	mixes := make([]*maptester.WorkloadMix, len(names))
+	for i, name := range names {
+		mix, err := maptester.ParseWorkloadMix(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		mixes[i] = mix
+	}
+	maptester.SelectWorkloadMixes(mixes)
+	return nil
+}
+
+// scenariosFlag selects the scenarios of the populate mix from a comma separated list like interleaved,phased
+type scenariosFlag struct{}
+
+func (sf *scenariosFlag) String() string {
+	return fmt.Sprint(maptester.Scenarios)
+}
+
+func (sf *scenariosFlag) Set(value string) error {
+	names := strings.Split(value, ",")
+	scenarios := make([]string, len(names))
+	for i, name := range names {
+		scenario, err := maptester.ParseScenario(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		scenarios[i] = scenario
+	}
+	maptester.SelectScenarios(scenarios)
+	return nil
+}
+
+// procsFlag selects the GOMAXPROCS dimension from a comma separated list like 1,4,128
+type procsFlag struct{}
+
+func (p *procsFlag) String() string {
+	return fmt.Sprint(maptester.GoMaxProcsValues)
+}
+
+func (p *procsFlag) Set(value string) error {
+	values, err := utils.ParseSizes(value)
+	if err != nil {
+		return err
+	}
+	return maptester.SelectGoMaxProcs(values)
+}
+
+// gcFlag selects the GC percent dimension from a comma separated list like 50,100,off
+type gcFlag struct{}
+
+func (g *gcFlag) String() string {
+	return fmt.Sprint(maptester.GCPercentValues)
+}
+
+func (g *gcFlag) Set(value string) error {
+	values, err := maptester.ParseGCPercents(value)
+	if err != nil {
+		return err
+	}
+	maptester.SelectGCPercents(values)
+	return nil
+}
+
+// memoryLimitFlag selects the soft memory limit dimension from a comma separated list like none,512M,2G
+type memoryLimitFlag struct{}
+
+func (m *memoryLimitFlag) String() string {
+	return fmt.Sprint(maptester.MemoryLimitValues)
+}
+
+func (m *memoryLimitFlag) Set(value string) error {
+	values, err := maptester.ParseMemoryLimits(value)
+	if err != nil {
+		return err
+	}
+	maptester.SelectMemoryLimits(values)
+	return nil
+}
+
+// configFlag loads the experiment matrix from a JSON file
+type configFlag struct {
+	filename string
+}
+
+func (c *configFlag) String() string {
+	return c.filename
+}
+
+func (c *configFlag) Set(value string) error {
+	c.filename = value
+	return maptester.LoadExperimentConfig(value)
+}
+
+// addDimensionFlags adds the flags selecting the values of the data and run dimensions
+func addDimensionFlags(flags *flag.FlagSet) {
+	flags.Var(new(configFlag), "config", "JSON file of the experiment dimensions, constraints, map types and ratio to run, "+
+		"the next dimension flags override it")
+	flags.Var(new(sizesFlag), "size", "comma separated data sizes (like 10K,100K,1M), default "+
+		strings.Trim(fmt.Sprint(maptester.DataSizes), "[]"))
+	flags.Var(new(valuesFlag), "values", "comma separated value size distributions: fixed v12, uniform vu16-1024, "+
+		"log-normal vl128-s10 (median, sigma in tenths), bimodal vb16-4096-p10 (small, large, percent large), default "+
+		strings.Trim(fmt.Sprint(maptester.ValueSizes), "[]"))
+	flags.Var(new(mixesFlag), "mix", "comma separated workload mixes: populate, ycsbA to ycsbF, or percent of "+
+		"r read, i insert, u update, m read-modify-write, d delete, s scan with uniform, zipfian or latest keys "+
+		"like r90i5d5-latest, default "+strings.Trim(fmt.Sprint(maptester.WorkloadMixes), "[]"))
+	flags.Var(new(scenariosFlag), "scenario", "comma separated scenarios of the populate mix: interleaved "+
+		"(readers start with the writers) or phased (readers start when all lines are inserted), default "+
+		strings.Trim(fmt.Sprint(maptester.Scenarios), "[]"))
+	flags.Var(new(procsFlag), "procs", "comma separated GOMAXPROCS applied while the tests run, below the number of threads to under-subscribe, default "+
+		strings.Trim(fmt.Sprint(maptester.GoMaxProcsValues), "[]"))
+	flags.Var(new(gcFlag), "gc", "comma separated GC percents of the tests like 50,100,off, default "+
+		strings.Trim(fmt.Sprint(maptester.GCPercentValues), "[]"))
+	flags.Var(new(memoryLimitFlag), "memory-limit", "comma separated soft memory limits of the tests like 512M,2G, default none")
+}
+
+// harnessFlag selects how the read threads choose their keys
+type harnessFlag struct{}
+
+func (h *harnessFlag) String() string {
+	return maptester.Harness
+}
+
+func (h *harnessFlag) Set(value string) error {
+	return maptester.SelectHarness(value)
+}
+
+// profileFlag selects the profiles captured during each test
+type profileFlag struct{}
+
+func (p *profileFlag) String() string {
+	return strings.Join(maptester.Profiles, ",")
+}
+
+func (p *profileFlag) Set(value string) error {
+	return maptester.SelectProfiles(value)
+}
+
+// mapsFlag selects the map types from a comma separated list
+type mapsFlag struct{}
+
+func (m *mapsFlag) String() string {
+	return strings.Join(maptester.SelectedMapTypes, ",")
+}
+
+func (m *mapsFlag) Set(value string) error {
+	return maptester.SelectMapTypes(strings.Split(value, ","))
+}
+
+// runFlag selects the run configurations matching a regular expression
+type runFlag struct{}
+
+func (r *runFlag) String() string {
+	if maptester.RunFilter == nil {
+		return ""
+	}
+	return maptester.RunFilter.String()
+}
+
+func (r *runFlag) Set(value string) error {
+	return maptester.SelectRuns(value)
+}
+
+// whereFlag adds conditions on the dimensions of the run configurations
+type whereFlag struct{}
+
+func (w *whereFlag) String() string {
+	return fmt.Sprint(maptester.WhereConditions)
+}
+
+func (w *whereFlag) Set(value string) error {
+	return maptester.AddWhereConditions(value)
+}
+
+func addFilterFlags(flags *flag.FlagSet) {
+	flags.Var(new(mapsFlag), "map", "comma separated map types to test, default all")
+	flags.Var(new(runFlag), "run", "regular expression the whole run name should match, like 'int3d-c50-.*-wt32-.*'")
+	flags.Var(new(whereFlag), "where", "comma separated conditions on dimensions like 'percent miss=0.5,nb read threads>=4', can be repeated")
+	flags.BoolVar(&maptester.DryRun, "dry-run", maptester.DryRun, "list the selected tests and the estimated time without running them")
+}
+
+func addRepetitionFlags(flags *flag.FlagSet) {
+	flags.IntVar(&maptester.WarmupRuns, "warmup", maptester.WarmupRuns, "runs of each test before the measured ones, not reported")
+	flags.IntVar(&maptester.Repetitions, "repeat", maptester.Repetitions, "measured runs of each test, reported with mean, stddev, min and 95% confidence interval")
+}
+
+func parseSizeFlags(c string) {
+	flags := flag.NewFlagSet(c, flag.ExitOnError)
+	addDimensionFlags(flags)
+	parseFlags(flags, os.Args[2:])
+}
+
+func parseGenFlags(c string) {
+	flags := flag.NewFlagSet(c, flag.ExitOnError)
+	addDimensionFlags(flags)
+	flags.IntVar(&maptester.GenWorkers, "workers", maptester.GenWorkers, "max number of data files generated in parallel")
+	flags.Int64Var(&maptester.GenSeed, "seed", maptester.GenSeed, "seed used to derive all data sets")
+	flags.BoolVar(&maptester.CompressData, "compress", maptester.CompressData, "gzip compress the data file blocks")
+	parseFlags(flags, os.Args[2:])
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) {
+	err := flags.Parse(args)
+	if err != nil {
+		usage()
+		os.Exit(2)
+	}
+}
+
 func usage() {
 	fmt.Printf("Usage: $ maptester [command] (name) (options)\n" +
-		"\tcommand: help, show, clean, gen, regen, read [name], test, analyze [list of file names]\n")
+		"\tcommand: help, show, clean, gen, regen, convert, read [name], import [file], trace [run name], replay [trace file], test, analyze [list of file names], overhead [list of file names]\n" +
+		"\tshow, clean, gen, regen, convert and test options: -size [comma separated data sizes like 10K,1M]\n" +
+		"\t\t-values [comma separated value size distributions like v12,vu16-1024,vl128-s10,vb16-4096-p10]\n" +
+		"\t\t-mix [comma separated workload mixes like populate,ycsbA,r90i5d5-latest]\n" +
+		"\t\t-scenario [comma separated populate scenarios interleaved,phased]\n" +
+		"\t\t-procs [comma separated GOMAXPROCS of the test runs like 1,4,128] -gc [comma separated GC percents like 50,100,off]\n" +
+		"\t\t-memory-limit [comma separated soft memory limits like none,512M,2G]\n" +
+		"\t\t-config [JSON experiment file, saved with the test results]\n" +
+		"\tgen and regen options: -workers [max parallel generation] -seed [data seed] -compress\n" +
+		"\tread options: -size [data size if name is not a data set name] -out [JSON verification report file]\n" +
+		"\timport options: -name [data configuration name] -key [int3d or string] -seed [missing values seed]\n" +
+		"\ttrace options: -size -seed [operations seed] -out [trace file]\n" +
+		"\treplay options: -size -map [comma separated map types] -warmup -repeat\n" +
+		"\ttest options: -record [write the trace of each test] -duration [run each test for a duration like 10s]\n" +
+		"\t\t-latency-sample [latency of one operation every n per thread, default 64, 0 to disable]\n" +
+		"\t\t-heap-sample [period of the in-use heap samples, default 10ms, 0 to disable]\n" +
+		"\t\t-profile [comma separated profiles cpu,heap,mutex,block,trace]\n" +
+		"\t\t-harness [keys of the read threads precomputed, local-rand or shared-rand]\n" +
+		"\t\t-warmup [dropped runs before the measured ones] -repeat [measured runs of each test]\n" +
+		"\t\t-map [comma separated map types] -run [run name regular expression] -where [conditions like 'percent miss=0.5']\n" +
+		"\t\t-dry-run [list the selected tests and the estimated time] -resume [results CSV of an interrupted run]\n" +
+		"\t\t-isolate [run each test in a child process] -timeout [maximum duration of a child process like 30m]\n")
 }
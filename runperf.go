@@ -5,22 +5,16 @@ import (
 	"fmt"
 	"github.com/freddy33/maptester/utils"
 	"github.com/google/logger"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-func Verify(name string, im *IntMapTestDataSet, result *DataFileReport) bool {
-	if int32(im.size) != result.NbLines {
-		logger.Errorf("Dataset %s does not have matching lines %d != %d", name, im.size, result.NbLines)
-		return false
-	}
-	return true
-}
-
 func getAllRunnableTests() []*MapPerfTestResult {
 	// Filter key types and concurrent write for non concurrent maps
 	result := make([]*MapPerfTestResult, 0, len(RunConfigurations)*2)
@@ -29,8 +23,11 @@ func getAllRunnableTests() []*MapPerfTestResult {
 		if rc.dataConf.keyType != KeyTypes[0] {
 			continue
 		}
+		if !rc.isSelected() {
+			continue
+		}
 		for _, mt := range MapTypes {
-			if !mt.isConcurrentWrite && rc.testConf.nbWriteThreads > 1 {
+			if !mt.isSelected() || !mt.supports(rc) {
 				// skip cannot be used
 				continue
 			}
@@ -49,59 +46,138 @@ func getAllRunnableTests() []*MapPerfTestResult {
 
 var MaxTests = 100000
 
+// Rough duration of one test on a data set of default size
+const TestSecondsPerDefaultSize = 10.0
+
+// estimatedSeconds estimates the execution time of tests, proportional to the data sizes and the number of runs
+func estimatedSeconds(perfTests []*MapPerfTestResult) float32 {
+	nbRuns := float32(WarmupRuns + Repetitions)
+	total := float32(0.0)
+	if TestDuration > 0 {
+		return float32(TestDuration.Seconds()) * float32(len(perfTests)) * nbRuns
+	}
+	for _, perfTest := range perfTests {
+		total += TestSecondsPerDefaultSize * float32(perfTest.runConf.dataConf.size) / float32(DefaultDataSize)
+	}
+	return total * nbRuns
+}
+
 func TestAll() bool {
 	globalStopWatch := NewStopWatch()
 	globalStopWatch.init()
 	globalLines := 0
 
 	allPass := true
-	perfTests := getAllRunnableTests()
+	var perfTests []*MapPerfTestResult
+	if ResumeFile != "" {
+		var err error
+		perfTests, err = readTestPlan(ResumeFile)
+		if err != nil {
+			logger.Errorf("Cannot resume %s due to %v", ResumeFile, err)
+			return false
+		}
+	} else {
+		perfTests = getAllRunnableTests()
+		rand.Seed(time.Now().UnixNano())
+		rand.Shuffle(len(perfTests), func(i, j int) { perfTests[i], perfTests[j] = perfTests[j], perfTests[i] })
+	}
 	totalTests := len(perfTests)
+	if DryRun {
+		if totalTests > MaxTests {
+			perfTests = perfTests[:MaxTests]
+		}
+		displayDryRun(perfTests)
+		return true
+	}
 
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(totalTests, func(i, j int) { perfTests[i], perfTests[j] = perfTests[j], perfTests[i] })
-
-	csvResultFile := openCsvFile(totalTests)
+	// The tests already done in the resumed results
+	var done map[PerfLineKey]PerfLineMeasurement
+	var csvResultFile *os.File
+	idx := 0
+	if ResumeFile != "" {
+		done = readAllPerfLines(ResumeFile)
+		csvResultFile, idx = openCsvFileForResume(ResumeFile)
+		fmt.Println("Resuming", ResumeFile, "with", len(done), "tests done")
+	} else {
+		csvResultFile = openCsvFile(totalTests)
+	}
 	defer utils.CloseFile(csvResultFile)
 
-	fmt.Println("Found", totalTests, "runnable tests for", GenDataSize)
-	idx := 0
+	fmt.Println("Found", totalTests, "runnable tests for sizes", DataSizes)
 	if totalTests > MaxTests {
 		totalTests = MaxTests
 	}
-	fmt.Println("Starting execution of", totalTests, "tests")
+	if ResumeFile == "" {
+		writeTestPlan(csvResultFile.Name(), perfTests[:totalTests])
+	}
+	resumed := make(map[*MapPerfTestResult]bool, len(done))
+	fmt.Println("Starting execution of", totalTests-len(done), "tests")
 	for _, dc := range DataConfigurations {
 		// TODO: support only int3d for now
 		if dc.keyType != KeyTypes[0] {
 			continue
 		}
-		currentDataName := dc.GetDataFileName()
-		im, report := ReadIntData(currentDataName, GenDataSize)
+		var im *IntMapTestDataSet
+		var report *DataFileReport
+		if IsolateTests {
+			// Each child process reads the data set
+			report = ReadIntDataFileReport(dc.GetDataFileName(), dc.size)
+		} else {
+			im, report = ReadIntData(dc.GetDataFileName(), dc.size)
+		}
+		if report == nil {
+			logger.Errorf("Skipping all tests of data set %s since it cannot be read", dc.GetDataSetName())
+			allPass = false
+			continue
+		}
 		for _, perfTest := range perfTests {
-			if perfTest.runConf.dataConf.GetDataFileName() != currentDataName {
+			if perfTest.runConf.dataConf != dc {
 				// Not here
 				continue
 			}
 			perfTest.fill(report)
-			perfTest.testConcurrentMap(im)
-			if perfTest.NbErrors() > 0 {
-				allPass = false
+			if _, ok := done[perfTest.extractPerfLineKey().resumeKey()]; ok {
+				resumed[perfTest] = true
+				continue
+			}
+			perfTest.recordTrace = RecordTraces && perfTest.runConf.mix.isPopulate() && TestDuration == 0
+			if IsolateTests {
+				line := perfTest.runIsolated(idx, csvResultFile)
+				if line == nil {
+					allPass = false
+				} else {
+					if line.Errors > 0 {
+						allPass = false
+					}
+					globalLines += line.NbMapEntries
+				}
+			} else {
+				perfTest.runRepetitions(im)
+				if perfTest.trace != nil {
+					perfTest.saveTrace()
+				}
+				if perfTest.totalErrors() > 0 {
+					allPass = false
+				}
+				globalLines += perfTest.nbMapEntries
+				perfTest.dumpPerfData(idx, csvResultFile)
 			}
-			globalLines += perfTest.nbMapEntries
-			perfTest.dumpPerfData(idx, csvResultFile)
 			idx++
 			fmt.Println("Did test", idx, "out of", totalTests, "reached", (100.0*float32(idx))/float32(totalTests), "%")
 			if idx > MaxTests {
 				break
 			}
 		}
+		if im != nil {
+			im.close()
+		}
 		if idx > MaxTests {
 			break
 		}
 	}
 
 	for _, perfTest := range perfTests {
-		if !perfTest.wasDone() {
+		if !perfTest.wasDone() && !resumed[perfTest] {
 			logger.Errorf("Expected to run %s - %s test", perfTest.runConf.GetRunName(), perfTest.mapTypeName)
 		}
 	}
@@ -115,17 +191,31 @@ func TestAll() bool {
 
 const SEP_CSV = ";"
 
+func dataSizesName() string {
+	sizes := make([]string, len(DataSizes))
+	for i, size := range DataSizes {
+		sizes[i] = fmt.Sprintf("%08d", size)
+	}
+	return strings.Join(sizes, "_")
+}
+
 func openCsvFile(nbTests int) *os.File {
 	// Mon Jan 2 15:04:05 -0700 MST 2006
-	perfOutFileName := filepath.Join(utils.GetOutPerfDir(), fmt.Sprintf("maptests-%03d-%08d-%s.csv",
-		nbTests, GenDataSize, time.Now().Format("2006-01-02_15_04_05")))
+	perfOutFileName := filepath.Join(utils.GetOutPerfDir(), fmt.Sprintf("maptests-%03d-%s-%s.csv",
+		nbTests, dataSizesName(), time.Now().Format("2006-01-02_15_04_05")))
 
 	outFile, err := os.OpenFile(perfOutFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0665)
 	if err != nil {
 		logger.Fatalf("cannot create perf out file %q due to %v", perfOutFileName, err)
 		return nil
 	}
+	writeCsvHeader(outFile)
+	saveExperimentConfig(perfOutFileName)
+	setProfileDir(perfOutFileName)
+	return outFile
+}
 
+func writeCsvHeader(outFile io.Writer) {
 	var headerRow bytes.Buffer
 	// Test index
 	headerRow.WriteString("idx")
@@ -151,110 +241,261 @@ func openCsvFile(nbTests int) *os.File {
 	// Calculated
 	headerRow.WriteString("nb read done")
 	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("harness")
+	headerRow.WriteString(SEP_CSV)
 	// The measurements
+	headerRow.WriteString("repetitions")
+	headerRow.WriteString(SEP_CSV)
 	headerRow.WriteString("exec duration")
 	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("exec duration stddev")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("exec duration min")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("exec duration ci95")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("write duration")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("read duration")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("mixed duration")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("target duration")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("setup duration")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("start skew")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("write finish skew")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("read finish skew")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("write throughput")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("read throughput")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("mixed throughput")
+	headerRow.WriteString(SEP_CSV)
+	for _, header := range append(latencyHeaders("load"), latencyHeaders("store")...) {
+		headerRow.WriteString(header)
+		headerRow.WriteString(SEP_CSV)
+	}
 	headerRow.WriteString("memory usage")
 	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("retained heap")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("bytes per entry")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("peak heap")
+	headerRow.WriteString(SEP_CSV)
 	headerRow.WriteString("GC done")
 	headerRow.WriteString(SEP_CSV)
 	headerRow.WriteString("errors")
 	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("status")
+	headerRow.WriteString(SEP_CSV)
+	headerRow.WriteString("profiles")
+	headerRow.WriteString(SEP_CSV)
 	headerRow.WriteString("\n")
-	utils.WriteNextString(outFile, headerRow.String())
-
-	return outFile
+	_, err := io.WriteString(outFile, headerRow.String())
+	utils.ExitOnError(err)
 }
 
-func (mp *MapPerfTestResult) dumpPerfData(idx int, outFile *os.File) {
+func (mp *MapPerfTestResult) dumpPerfData(idx int, outFile io.Writer) {
 	dataConf := mp.runConf.dataConf
 	testConf := mp.runConf.testConf
 	diff := mp.memDiff()
-	utils.WriteNextString(outFile,
-		fmt.Sprintf("%d;%s;%s;%f;%f;%f;%f;%d;%d;%s;%d;%d;%d;%d;%d;%d;%d;%d;%d;\n",
-			idx, mp.Name(),
-			dataConf.keyType, testConf.initRatio, dataConf.conflictRatio,
-			mp.runConf.readWriteThreadRatio, testConf.percentMiss, mp.runConf.readWriteNbRatio, dataConf.valueSize,
-			mp.mapTypeName, mp.dataReport.NbLines, mp.nbMapEntries,
-			testConf.nbWriteThreads, testConf.nbReadThreads, testConf.nbReadTest*testConf.nbReadThreads,
-			mp.execDuration().Microseconds(), diff.TotalAlloc, diff.NumGC, mp.NbErrors()))
+	repetitions, execMean, execStddev, execMin, execCI95 := mp.execDurationStats()
+	_, err := fmt.Fprintf(outFile, "%d;%s;%s;%f;%f;%f;%f;%d;%s;%d;%s;%s;%d;%d;%d;%s;%d;%d;%d;%d;%d;%s;%d;%.0f;%f;%.0f;%f;%d;%d;%d;%d;%d;%d;%d;%d;%f;%f;%f;%s%d;%d;%f;%d;%d;%d;%s;%s;\n",
+		idx, mp.Name(),
+		dataConf.keyType, testConf.initRatio, dataConf.conflictRatio,
+		mp.runConf.readWriteThreadRatio, testConf.percentMiss, mp.runConf.readWriteNbRatio, dataConf.valueSize.Name(),
+		dataConf.size, mp.runConf.mix.Name(), mp.runConf.scenario,
+		mp.runConf.runtime.goMaxProcs, mp.runConf.runtime.gcPercent, mp.runConf.runtime.memoryLimit,
+		mp.mapTypeName, mp.dataReport.NbLines, mp.nbMapEntries,
+		testConf.nbWriteThreads, testConf.nbReadThreads, mp.nbReadDone(), mp.harness,
+		repetitions, execMean, execStddev, execMin, execCI95,
+		mp.phases.write.Microseconds(), mp.phases.read.Microseconds(), mp.phases.mixed.Microseconds(), mp.targetDuration.Microseconds(),
+		mp.setupDuration.Microseconds(), mp.skews.startSkew.Microseconds(), mp.skews.writeFinish.spread().Microseconds(), mp.skews.readFinish.spread().Microseconds(),
+		mp.phases.writeThroughput(), mp.phases.readThroughput(), mp.phases.mixedThroughput(), mp.latencies.csvFields(),
+		diff.TotalAlloc, mp.heap.retained, mp.heap.bytesPerEntry(mp.nbMapEntries), mp.heap.peak,
+		diff.NumGC, mp.totalErrors(), mp.getStatus(), strings.Join(mp.profileFiles, ","))
+	utils.ExitOnError(err)
 }
 
 func (mp *MapPerfTestResult) testConcurrentMap(im *IntMapTestDataSet) {
+	// The reads of a populate test are prepared before the heap baseline
+	populate := mp.runConf.mix.isPopulate() && (TestDuration <= 0 || mp.trace != nil)
+	var reads *readHarness
+	if populate {
+		reads = mp.prepareReads(im)
+	}
+	mp.heap.start()
+	setupStart := time.Now()
 	m := mp.CreateMap()
+	mp.setupDuration = time.Since(setupStart)
+	if !mp.runConf.mix.isPopulate() {
+		mp.testWorkloadMix(m, im)
+		return
+	}
+	if !populate {
+		mp.testThroughput(m, im)
+		return
+	}
 	conf := mp.runConf.testConf
+	replay := reads.replay
+	writeOps := func(i int) []traceOp {
+		if replay {
+			return mp.trace.writeOps(i)
+		}
+		return nil
+	}
+
+	nbReadOps := conf.nbReadThreads * conf.nbReadTest
+	if replay {
+		nbReadOps = mp.trace.nbOps() - im.size
+	}
+	// Readers start when all lines are inserted, or at the same time as writers
+	nbWriteThreads := conf.nbWriteThreads
+	phased := mp.runConf.scenario == PhasedScenario
+	if !m.SupportConcurrentWrite() {
+		nbWriteThreads = 1
+		phased = true
+	}
+	writeBarrier := newStartBarrier(nbWriteThreads + conf.nbReadThreads)
+	readBarrier := writeBarrier
+	if phased {
+		writeBarrier = newStartBarrier(nbWriteThreads)
+		readBarrier = newStartBarrier(conf.nbReadThreads)
+	}
 
-	mp.init()
 	readWaitGroup := new(sync.WaitGroup)
 	writeWaitGroup := new(sync.WaitGroup)
 	doneWriting := uint32(0)
-	if m.SupportConcurrentWrite() {
-		size := im.size / conf.nbWriteThreads
-		writeWaitGroup.Add(conf.nbWriteThreads)
-		for i := 0; i < conf.nbWriteThreads; i++ {
-			offset := size * i
-			go testLoadAndStore(m, im, offset, size, mp, writeWaitGroup)
-		}
-	} else {
-		writeWaitGroup.Add(1)
-		testLoadAndStore(m, im, 0, im.size, mp, writeWaitGroup)
-		doneWriting = uint32(1)
+	writeWaitGroup.Add(nbWriteThreads)
+	for i := 0; i < nbWriteThreads; i++ {
+		offset, size := writeSegment(im.size, nbWriteThreads, i)
+		ops := writeOps(i)
+		mp.startWorker(writeBarrier, true, writeWaitGroup, func() {
+			testLoadAndStore(m, im, offset, size, ops, mp)
+		})
 	}
-
 	readWaitGroup.Add(conf.nbReadThreads)
 	for i := 0; i < conf.nbReadThreads; i++ {
-		go testLoad(m, im, conf.nbReadTest, &doneWriting, mp, readWaitGroup)
+		var replayOps, recordOps []traceOp
+		if replay || (mp.recordTrace && mp.harness == HarnessPrecomputed) {
+			replayOps = mp.trace.readOps(i)
+		} else if reads.sequences != nil {
+			replayOps = reads.sequences[i]
+		} else if mp.recordTrace {
+			recordOps = mp.trace.readOps(i)
+		}
+		rnd := reads.rnds[i]
+		mp.startWorker(readBarrier, false, readWaitGroup, func() {
+			testLoad(m, im, conf.nbReadTest, replayOps, recordOps, rnd, &doneWriting, mp)
+		})
 	}
+	writeBarrier.waitReady()
+	readBarrier.waitReady()
 
+	mp.init()
+	writeStart := writeBarrier.release()
 	writeWaitGroup.Wait()
+	mp.phases.setWrite(im.size, time.Since(writeStart))
 	atomic.AddUint32(&doneWriting, 1)
+	readStart := writeStart
+	if phased {
+		readStart = readBarrier.release()
+	}
 	readWaitGroup.Wait()
+	mp.phases.setRead(nbReadOps, time.Since(readStart))
 
 	mp.nbMapEntries = m.Size()
 	mp.stop()
+	mp.measureHeap(m)
 	mp.display(mp.Name())
 }
 
-func testLoadAndStore(m ConcurrentInt3Map, im *IntMapTestDataSet, offset, size int, perf *MapPerfTestResult, wg *sync.WaitGroup) {
+// testLoadAndStore inserts the lines of the segment, or the lines of the ops if not nil
+func testLoadAndStore(m ConcurrentInt3Map, im *IntMapTestDataSet, offset, size int, ops []traceOp, perf *MapPerfTestResult) {
 	errorsKeyNotSame := int32(0)
 	errorsValuesEqual := int32(0)
-	for i := offset; i < offset+size && i < im.size; i++ {
+	stores := new(latencyHistogram)
+	if ops != nil {
+		size = len(ops)
+	}
+	for j := 0; j < size; j++ {
+		i := offset + j
+		if ops != nil {
+			i = int(ops[j].keyIdx)
+		}
 		key := im.keys[i]
 		val := &im.values[i]
+		start := stores.start(j)
 		oldValue, loaded := m.LoadOrStore(key, &TestMapValue{val: val})
+		stores.end(start)
 		if loaded {
-			if im.keys[int(oldValue.val.Idx)] != im.keys[i] {
+			oldVal := oldValue.value()
+			if im.keys[int(oldVal.Idx)] != im.keys[i] {
 				errorsKeyNotSame++
 			}
-			if oldValue.val == val {
+			if oldVal == val {
 				errorsValuesEqual++
 			} else {
 				oldValue.overwriteVal(val)
 			}
 		}
 	}
+	perf.latencies.add(nil, stores)
 	atomic.AddInt32(&perf.errorsKeyNotSame, errorsKeyNotSame)
 	atomic.AddInt32(&perf.errorsValuesEqual, errorsValuesEqual)
-	wg.Done()
 }
 
-func testLoad(m ConcurrentInt3Map, im *IntMapTestDataSet, nbTest int, doneWritingAddr *uint32, perf *MapPerfTestResult, wg *sync.WaitGroup) {
+// testLoad reads nbTest random keys, or until stopped if nbTest is negative, or the keys of replayOps if not nil.
+// The random keys are drawn from rnd, or from the global source if nil, and saved in recordOps if not nil.
+func testLoad(m ConcurrentInt3Map, im *IntMapTestDataSet, nbTest int, replayOps, recordOps []traceOp, rnd *rand.Rand, doneWritingAddr *uint32, perf *MapPerfTestResult) {
 	errorsKeyFound := int32(0)
 	errorsKeyNotFound := int32(0)
 	errorsValuesNotEqual := int32(0)
 	errorsPointerValuesNotEqual := int32(0)
-	for i := 0; i < nbTest; i++ {
-		idx := int(rand.Int31n(int32(im.size)))
+	loads := new(latencyHistogram)
+	if replayOps != nil {
+		nbTest = len(replayOps)
+	}
+	untilStopped := nbTest < 0
+	i := 0
+	for ; untilStopped || i < nbTest; i++ {
+		if untilStopped && perf.isStopped(i) {
+			break
+		}
+		var idx int
+		var notKey bool
+		if replayOps != nil {
+			idx = int(replayOps[i].keyIdx)
+			notKey = replayOps[i].miss
+		} else {
+			if rnd != nil {
+				idx = int(rnd.Int31n(int32(im.size)))
+				notKey = rnd.Float32() < perf.runConf.testConf.percentMiss
+			} else {
+				idx = int(rand.Int31n(int32(im.size)))
+				notKey = rand.Float32() < perf.runConf.testConf.percentMiss
+			}
+			if recordOps != nil {
+				recordOps[i] = traceOp{op: TraceOpType_LOAD, keyIdx: int32(idx), miss: notKey}
+			}
+		}
 		var key Int3Key
-		notKey := rand.Float32() < perf.runConf.testConf.percentMiss
 		if notKey {
 			key = im.getNotKey(idx)
 		} else {
 			key = im.getKey(idx)
 		}
+		// Read before the load, the key may be inserted by the writers only after it
+		doneWriting := atomic.LoadUint32(doneWritingAddr) > 0
+		start := loads.start(i)
 		value, ok := m.Load(key)
-		//doneWriting := atomic.LoadUint32(doneWritingAddr) > 0
-		doneWriting := *doneWritingAddr > 0
+		loads.end(start)
 
 		if notKey {
 			if ok {
@@ -265,28 +506,30 @@ func testLoad(m ConcurrentInt3Map, im *IntMapTestDataSet, nbTest int, doneWritin
 				errorsKeyNotFound++
 			}
 			if ok {
-				if value.val.GetIdx() != int64(idx) {
+				val := value.value()
+				if val.GetIdx() != int64(idx) {
 					// It's an overwrite if done writing all
 					if doneWriting && !value.IsOverwritten() {
 						errorsValuesNotEqual++
 					}
 				} else {
 					// Make sure same pointer
-					if value.val != &(im.values[idx]) {
+					if val != &(im.values[idx]) {
 						errorsPointerValuesNotEqual++
 					}
 				}
 			}
 		}
 	}
+	perf.latencies.add(loads, nil)
+	atomic.AddInt64(&perf.readOpsDone, int64(i))
 	atomic.AddInt32(&perf.errorsKeyFound, errorsKeyFound)
 	atomic.AddInt32(&perf.errorsKeyNotFound, errorsKeyNotFound)
 	atomic.AddInt32(&perf.errorsValuesNotEqual, errorsValuesNotEqual)
 	atomic.AddInt32(&perf.errorsPointerValuesNotEqual, errorsPointerValuesNotEqual)
-	wg.Done()
 }
 
-// Testing different scenario:
+// Testing different scenario (see InterleavedScenario, PhasedScenario and SteadyScenario):
 // 1. First populate, then read
 //   1.1 standard map can only single thread populate but can do parallel read
 //   1.2 All concurrent maps can parallel populate then parallel reads
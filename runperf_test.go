@@ -0,0 +1,82 @@
+package maptester
+
+import (
+	"github.com/gocarina/gocsv"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScenariosPerfData(t *testing.T) {
+	size := 2000
+	im, report := newTestDataSet(size)
+	defer func(rate int) { LatencySampleRate = rate }(LatencySampleRate)
+	LatencySampleRate = 16
+	ycsbA, err := ParseWorkloadMix("ycsbA")
+	assert.NoError(t, err)
+	perfTests := make([]*MapPerfTestResult, 0, 3)
+	for _, scenario := range []string{InterleavedScenario, PhasedScenario, SteadyScenario} {
+		rc := newTestRunConfiguration(size, 2, 2, 1000)
+		rc.scenario = scenario
+		if scenario == SteadyScenario {
+			rc.mix = ycsbA
+		}
+		rc.fillRunName()
+		mp := &MapPerfTestResult{runConf: rc, mapTypeName: "RWMutex"}
+		mp.fill(report)
+		mp.testConcurrentMap(im)
+		assert.Equal(t, 0, mp.NbErrors(), mp.Name())
+		assert.True(t, mp.phases.write > 0 && mp.phases.writeThroughput() > 0, mp.Name())
+		assert.True(t, mp.latencies.load.count > 0 && mp.latencies.store.count > 0, mp.Name())
+		if scenario == SteadyScenario {
+			assert.Equal(t, 2000, mp.phases.mixedOps)
+			assert.True(t, mp.phases.mixedThroughput() > 0)
+			assert.Equal(t, 0.0, mp.phases.readThroughput())
+		} else {
+			assert.Equal(t, size, mp.phases.writeOps)
+			assert.Equal(t, 2000, mp.phases.readOps)
+			assert.True(t, mp.phases.readThroughput() > 0)
+		}
+		perfTests = append(perfTests, mp)
+	}
+
+	rc := newTestRunConfiguration(size, 1, 2, 1000)
+	assert.False(t, MapTypes[0].supports(rc), "basic map cannot interleave reads and writes")
+	rc.scenario = PhasedScenario
+	assert.True(t, MapTypes[0].supports(rc))
+
+	filename := filepath.Join(t.TempDir(), "perf.csv")
+	outFile, err := os.Create(filename)
+	assert.NoError(t, err)
+	writeCsvHeader(outFile)
+	for idx, mp := range perfTests {
+		mp.dumpPerfData(idx, outFile)
+	}
+	assert.NoError(t, outFile.Close())
+	inFile, err := os.Open(filename)
+	assert.NoError(t, err)
+	defer inFile.Close()
+	lines := []*PerfLine{}
+	assert.NoError(t, gocsv.UnmarshalFile(inFile, &lines))
+	assert.Equal(t, 3, len(lines))
+	for idx, line := range lines {
+		mp := perfTests[idx]
+		assert.Equal(t, mp.extractPerfLineKey().Scenario, line.Scenario)
+		assert.Equal(t, mp.runConf.mix.Name(), line.WorkloadMix)
+		assert.Equal(t, "v12", line.ValueSize)
+		assert.Equal(t, size, line.DataSize)
+		assert.Equal(t, mp.phases.write.Microseconds(), line.WriteDuration)
+		assert.Equal(t, mp.phases.mixed.Microseconds(), line.MixedDuration)
+		assert.InDelta(t, mp.phases.readThroughput(), line.ReadThroughput, 1.0)
+		assert.Equal(t, mp.NbErrors(), line.Errors)
+		assert.Equal(t, int64(mp.latencies.load.percentile(50.0)), line.LoadP50)
+		assert.Equal(t, mp.latencies.store.max, line.StoreMax)
+		assert.True(t, line.StoreMax > 0)
+		assert.Equal(t, int64(mp.heap.retained), line.RetainedHeap)
+		assert.True(t, line.RetainedHeap > 0 && line.BytesPerEntry > 0)
+		assert.Equal(t, DefaultGoMaxProcs, line.GoMaxProcs)
+		assert.Equal(t, DefaultGCPercent, line.GCPercent)
+		assert.Equal(t, mp.harness, line.Harness)
+	}
+}
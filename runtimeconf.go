@@ -0,0 +1,150 @@
+package maptester
+
+import (
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// The runtime settings of the tests by default, the GC percent of GOGC=100 and no soft memory limit
+const (
+	DefaultGoMaxProcs = MaxConThreads * 2
+	DefaultGCPercent  = 100
+	GCOff             = -1
+	NoMemoryLimit     = 0
+)
+
+// Used in Run Configuration, the GOMAXPROCS can be below the number of threads
+var GoMaxProcsValues = []int{DefaultGoMaxProcs}
+var GCPercentValues = []int{DefaultGCPercent}
+var MemoryLimitValues = []int64{NoMemoryLimit}
+
+// runtimeSettings are the GOMAXPROCS, GC percent and soft memory limit in bytes of a run configuration
+type runtimeSettings struct {
+	goMaxProcs  int
+	gcPercent   int
+	memoryLimit int64
+}
+
+func allRuntimeSettings() []runtimeSettings {
+	result := make([]runtimeSettings, 0, len(GoMaxProcsValues)*len(GCPercentValues)*len(MemoryLimitValues))
+	for _, procs := range GoMaxProcsValues {
+		for _, gc := range GCPercentValues {
+			for _, limit := range MemoryLimitValues {
+				result = append(result, runtimeSettings{goMaxProcs: procs, gcPercent: gc, memoryLimit: limit})
+			}
+		}
+	}
+	return result
+}
+
+// nameSuffix adds the settings different from the defaults to the run name, like -p04-gcoff-ml512M
+func (rs runtimeSettings) nameSuffix() string {
+	suffix := ""
+	if rs.goMaxProcs != DefaultGoMaxProcs {
+		suffix += fmt.Sprintf("-p%02d", rs.goMaxProcs)
+	}
+	if rs.gcPercent != DefaultGCPercent {
+		suffix += "-gc" + formatGCPercent(rs.gcPercent)
+	}
+	if rs.memoryLimit != NoMemoryLimit {
+		suffix += "-ml" + utils.FormatSize(rs.memoryLimit)
+	}
+	return suffix
+}
+
+// apply sets the runtime settings and returns the function restoring the previous ones
+func (rs runtimeSettings) apply() func() {
+	previousProcs := runtime.GOMAXPROCS(rs.goMaxProcs)
+	previousGCPercent := debug.SetGCPercent(rs.gcPercent)
+	previousLimit := int64(-1)
+	if rs.memoryLimit != NoMemoryLimit {
+		previousLimit = debug.SetMemoryLimit(rs.memoryLimit)
+	}
+	return func() {
+		runtime.GOMAXPROCS(previousProcs)
+		debug.SetGCPercent(previousGCPercent)
+		if previousLimit >= 0 {
+			debug.SetMemoryLimit(previousLimit)
+		}
+	}
+}
+
+func formatGCPercent(gcPercent int) string {
+	if gcPercent < 0 {
+		return "off"
+	}
+	return strconv.Itoa(gcPercent)
+}
+
+// ParseGCPercents parses a comma separated list of GC percents, off disabling the GC
+func ParseGCPercents(value string) ([]int, error) {
+	parts := strings.Split(value, ",")
+	result := make([]int, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "off" {
+			result[i] = GCOff
+			continue
+		}
+		gcPercent, err := strconv.Atoi(part)
+		if err != nil || gcPercent < 0 {
+			return nil, fmt.Errorf("GC percent %q in %q should be positive or off", part, value)
+		}
+		result[i] = gcPercent
+	}
+	return result, nil
+}
+
+// ParseMemoryLimits parses a comma separated list of soft memory limits like 512M,2G, none for no limit
+func ParseMemoryLimits(value string) ([]int64, error) {
+	parts := strings.Split(value, ",")
+	result := make([]int64, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "none" {
+			result[i] = NoMemoryLimit
+			continue
+		}
+		sizes, err := utils.ParseSizes(part)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = int64(sizes[0])
+	}
+	return result, nil
+}
+
+func checkGoMaxProcs(values []int) error {
+	for _, procs := range values {
+		if procs < 1 {
+			return fmt.Errorf("GOMAXPROCS %d should be positive", procs)
+		}
+	}
+	return nil
+}
+
+// SelectGoMaxProcs replaces the GOMAXPROCS dimension and rebuilds all configurations
+func SelectGoMaxProcs(values []int) error {
+	if err := checkGoMaxProcs(values); err != nil {
+		return err
+	}
+	GoMaxProcsValues = values
+	BuildConfigurations()
+	return nil
+}
+
+// SelectGCPercents replaces the GC percent dimension and rebuilds all configurations
+func SelectGCPercents(values []int) {
+	GCPercentValues = values
+	BuildConfigurations()
+}
+
+// SelectMemoryLimits replaces the soft memory limit dimension and rebuilds all configurations
+func SelectMemoryLimits(values []int64) {
+	MemoryLimitValues = values
+	BuildConfigurations()
+}
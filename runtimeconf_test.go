@@ -0,0 +1,65 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeSettings(t *testing.T) {
+	defaults := runtimeSettings{goMaxProcs: DefaultGoMaxProcs, gcPercent: DefaultGCPercent}
+	assert.Equal(t, "", defaults.nameSuffix())
+	rs := runtimeSettings{goMaxProcs: 4, gcPercent: GCOff, memoryLimit: 512 * 1000 * 1000}
+	assert.Equal(t, "-p04-gcoff-ml512M", rs.nameSuffix())
+
+	procs := runtime.GOMAXPROCS(0)
+	restore := runtimeSettings{goMaxProcs: 3, gcPercent: 50, memoryLimit: 1 << 40}.apply()
+	assert.Equal(t, 3, runtime.GOMAXPROCS(0))
+	assert.Equal(t, int64(1<<40), debug.SetMemoryLimit(-1))
+	restore()
+	assert.Equal(t, procs, runtime.GOMAXPROCS(0))
+	assert.NotEqual(t, int64(1<<40), debug.SetMemoryLimit(-1))
+	gcPercent := debug.SetGCPercent(100)
+	debug.SetGCPercent(gcPercent)
+	assert.NotEqual(t, 50, gcPercent)
+
+	gcPercents, err := ParseGCPercents("50, 100,off")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{50, 100, GCOff}, gcPercents)
+	_, err = ParseGCPercents("-50")
+	assert.Error(t, err)
+	limits, err := ParseMemoryLimits("none,512M,2G")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{NoMemoryLimit, 512 * 1000 * 1000, 2 * 1000 * 1000 * 1000}, limits)
+	_, err = ParseMemoryLimits("lots")
+	assert.Error(t, err)
+	assert.Error(t, SelectGoMaxProcs([]int{0}))
+}
+
+func TestRuntimeDimensions(t *testing.T) {
+	savedSizes := DataSizes
+	defer func() {
+		DataSizes, GoMaxProcsValues, GCPercentValues, MemoryLimitValues = savedSizes, []int{DefaultGoMaxProcs}, []int{DefaultGCPercent}, []int64{NoMemoryLimit}
+		BuildConfigurations()
+	}()
+	DataSizes = []int{1000}
+	BuildConfigurations()
+	nbRuns := len(RunConfigurations)
+	assert.NoError(t, SelectGoMaxProcs([]int{2, DefaultGoMaxProcs}))
+	SelectGCPercents([]int{GCOff})
+	SelectMemoryLimits([]int64{NoMemoryLimit, 1000 * 1000 * 1000})
+	assert.Equal(t, 4*nbRuns, len(RunConfigurations))
+	c, err := ParseCondition("gomaxprocs<4")
+	assert.NoError(t, err)
+	nbUnderSubscribed := 0
+	for name, rc := range RunConfigurations {
+		assert.True(t, strings.Contains(name, "-gcoff"), name)
+		if c.matches(rc) {
+			nbUnderSubscribed++
+			assert.True(t, strings.Contains(name, "-p02-gcoff"), name)
+		}
+	}
+	assert.Equal(t, 2*nbRuns, nbUnderSubscribed)
+}
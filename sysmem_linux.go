@@ -0,0 +1,14 @@
+package maptester
+
+import (
+	"syscall"
+)
+
+// totalMemory returns the physical memory of the machine, 0 if unknown
+func totalMemory() uint64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return uint64(info.Totalram) * uint64(info.Unit)
+}
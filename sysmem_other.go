@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package maptester
+
+// Without sysinfo the physical memory is unknown
+func totalMemory() uint64 {
+	return 0
+}
@@ -20,6 +20,31 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type TraceOpType int32
+
+const (
+	TraceOpType_LOAD          TraceOpType = 0
+	TraceOpType_LOAD_OR_STORE TraceOpType = 1
+)
+
+var TraceOpType_name = map[int32]string{
+	0: "LOAD",
+	1: "LOAD_OR_STORE",
+}
+
+var TraceOpType_value = map[string]int32{
+	"LOAD":          0,
+	"LOAD_OR_STORE": 1,
+}
+
+func (x TraceOpType) String() string {
+	return proto.EnumName(TraceOpType_name, int32(x))
+}
+
+func (TraceOpType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_40c4782d007dfce9, []int{0}
+}
+
 type TestValue struct {
 	SVal                 string   `protobuf:"bytes,1,opt,name=sVal,proto3" json:"sVal,omitempty"`
 	Idx                  int64    `protobuf:"varint,2,opt,name=idx,proto3" json:"idx,omitempty"`
@@ -162,14 +187,21 @@ func (m *StringTestLine) GetValue() *TestValue {
 }
 
 type DataFileReport struct {
-	NbLines              int32    `protobuf:"varint,1,opt,name=nbLines,proto3" json:"nbLines,omitempty"`
-	NbEntries            int32    `protobuf:"varint,2,opt,name=nbEntries,proto3" json:"nbEntries,omitempty"`
-	NbSameKeys           int32    `protobuf:"varint,3,opt,name=nbSameKeys,proto3" json:"nbSameKeys,omitempty"`
-	NbOfTimesSameKey     []int32  `protobuf:"varint,4,rep,packed,name=nbOfTimesSameKey,proto3" json:"nbOfTimesSameKey,omitempty"`
-	OffsetsPerThreads    []int32  `protobuf:"varint,5,rep,packed,name=offsetsPerThreads,proto3" json:"offsetsPerThreads,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	NbLines               int32    `protobuf:"varint,1,opt,name=nbLines,proto3" json:"nbLines,omitempty"`
+	NbEntries             int32    `protobuf:"varint,2,opt,name=nbEntries,proto3" json:"nbEntries,omitempty"`
+	NbSameKeys            int32    `protobuf:"varint,3,opt,name=nbSameKeys,proto3" json:"nbSameKeys,omitempty"`
+	NbOfTimesSameKey      []int32  `protobuf:"varint,4,rep,packed,name=nbOfTimesSameKey,proto3" json:"nbOfTimesSameKey,omitempty"`
+	OffsetsPerThreads     []int64  `protobuf:"varint,5,rep,packed,name=offsetsPerThreads,proto3" json:"offsetsPerThreads,omitempty"`
+	FileSize              int64    `protobuf:"varint,6,opt,name=fileSize,proto3" json:"fileSize,omitempty"`
+	RawSize               int64    `protobuf:"varint,7,opt,name=rawSize,proto3" json:"rawSize,omitempty"`
+	Compression           string   `protobuf:"bytes,8,opt,name=compression,proto3" json:"compression,omitempty"`
+	ValueSizeDistribution string   `protobuf:"bytes,9,opt,name=valueSizeDistribution,proto3" json:"valueSizeDistribution,omitempty"`
+	MinValueSize          int32    `protobuf:"varint,10,opt,name=minValueSize,proto3" json:"minValueSize,omitempty"`
+	MaxValueSize          int32    `protobuf:"varint,11,opt,name=maxValueSize,proto3" json:"maxValueSize,omitempty"`
+	TotalValueSize        int64    `protobuf:"varint,12,opt,name=totalValueSize,proto3" json:"totalValueSize,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{} `json:"-"`
+	XXX_unrecognized      []byte   `json:"-"`
+	XXX_sizecache         int32    `json:"-"`
 }
 
 func (m *DataFileReport) Reset()         { *m = DataFileReport{} }
@@ -225,18 +257,324 @@ func (m *DataFileReport) GetNbOfTimesSameKey() []int32 {
 	return nil
 }
 
-func (m *DataFileReport) GetOffsetsPerThreads() []int32 {
+func (m *DataFileReport) GetOffsetsPerThreads() []int64 {
 	if m != nil {
 		return m.OffsetsPerThreads
 	}
 	return nil
 }
 
+func (m *DataFileReport) GetFileSize() int64 {
+	if m != nil {
+		return m.FileSize
+	}
+	return 0
+}
+
+func (m *DataFileReport) GetRawSize() int64 {
+	if m != nil {
+		return m.RawSize
+	}
+	return 0
+}
+
+func (m *DataFileReport) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
+func (m *DataFileReport) GetValueSizeDistribution() string {
+	if m != nil {
+		return m.ValueSizeDistribution
+	}
+	return ""
+}
+
+func (m *DataFileReport) GetMinValueSize() int32 {
+	if m != nil {
+		return m.MinValueSize
+	}
+	return 0
+}
+
+func (m *DataFileReport) GetMaxValueSize() int32 {
+	if m != nil {
+		return m.MaxValueSize
+	}
+	return 0
+}
+
+func (m *DataFileReport) GetTotalValueSize() int64 {
+	if m != nil {
+		return m.TotalValueSize
+	}
+	return 0
+}
+
+type DataFileHeader struct {
+	Version               int32    `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Seed                  int64    `protobuf:"varint,2,opt,name=seed,proto3" json:"seed,omitempty"`
+	KeyType               string   `protobuf:"bytes,3,opt,name=keyType,proto3" json:"keyType,omitempty"`
+	ConflictRatio         float32  `protobuf:"fixed32,4,opt,name=conflictRatio,proto3" json:"conflictRatio,omitempty"`
+	ValueSize             int32    `protobuf:"varint,5,opt,name=valueSize,proto3" json:"valueSize,omitempty"`
+	NbLines               int32    `protobuf:"varint,6,opt,name=nbLines,proto3" json:"nbLines,omitempty"`
+	NbLinesPerBlock       int32    `protobuf:"varint,7,opt,name=nbLinesPerBlock,proto3" json:"nbLinesPerBlock,omitempty"`
+	Compression           string   `protobuf:"bytes,8,opt,name=compression,proto3" json:"compression,omitempty"`
+	ValueSizeDistribution string   `protobuf:"bytes,9,opt,name=valueSizeDistribution,proto3" json:"valueSizeDistribution,omitempty"`
+	XXX_NoUnkeyedLiteral  struct{} `json:"-"`
+	XXX_unrecognized      []byte   `json:"-"`
+	XXX_sizecache         int32    `json:"-"`
+}
+
+func (m *DataFileHeader) Reset()         { *m = DataFileHeader{} }
+func (m *DataFileHeader) String() string { return proto.CompactTextString(m) }
+func (*DataFileHeader) ProtoMessage()    {}
+func (*DataFileHeader) Descriptor() ([]byte, []int) {
+	return fileDescriptor_40c4782d007dfce9, []int{4}
+}
+
+func (m *DataFileHeader) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DataFileHeader.Unmarshal(m, b)
+}
+func (m *DataFileHeader) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DataFileHeader.Marshal(b, m, deterministic)
+}
+func (m *DataFileHeader) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DataFileHeader.Merge(m, src)
+}
+func (m *DataFileHeader) XXX_Size() int {
+	return xxx_messageInfo_DataFileHeader.Size(m)
+}
+func (m *DataFileHeader) XXX_DiscardUnknown() {
+	xxx_messageInfo_DataFileHeader.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DataFileHeader proto.InternalMessageInfo
+
+func (m *DataFileHeader) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetSeed() int64 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetKeyType() string {
+	if m != nil {
+		return m.KeyType
+	}
+	return ""
+}
+
+func (m *DataFileHeader) GetConflictRatio() float32 {
+	if m != nil {
+		return m.ConflictRatio
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetValueSize() int32 {
+	if m != nil {
+		return m.ValueSize
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetNbLines() int32 {
+	if m != nil {
+		return m.NbLines
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetNbLinesPerBlock() int32 {
+	if m != nil {
+		return m.NbLinesPerBlock
+	}
+	return 0
+}
+
+func (m *DataFileHeader) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
+func (m *DataFileHeader) GetValueSizeDistribution() string {
+	if m != nil {
+		return m.ValueSizeDistribution
+	}
+	return ""
+}
+
+type TraceHeader struct {
+	Version              int32    `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	RunName              string   `protobuf:"bytes,2,opt,name=runName,proto3" json:"runName,omitempty"`
+	DataSetName          string   `protobuf:"bytes,3,opt,name=dataSetName,proto3" json:"dataSetName,omitempty"`
+	NbLines              int32    `protobuf:"varint,4,opt,name=nbLines,proto3" json:"nbLines,omitempty"`
+	NbWriteThreads       int32    `protobuf:"varint,5,opt,name=nbWriteThreads,proto3" json:"nbWriteThreads,omitempty"`
+	NbReadThreads        int32    `protobuf:"varint,6,opt,name=nbReadThreads,proto3" json:"nbReadThreads,omitempty"`
+	Seed                 int64    `protobuf:"varint,7,opt,name=seed,proto3" json:"seed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TraceHeader) Reset()         { *m = TraceHeader{} }
+func (m *TraceHeader) String() string { return proto.CompactTextString(m) }
+func (*TraceHeader) ProtoMessage()    {}
+func (*TraceHeader) Descriptor() ([]byte, []int) {
+	return fileDescriptor_40c4782d007dfce9, []int{5}
+}
+
+func (m *TraceHeader) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TraceHeader.Unmarshal(m, b)
+}
+func (m *TraceHeader) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TraceHeader.Marshal(b, m, deterministic)
+}
+func (m *TraceHeader) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceHeader.Merge(m, src)
+}
+func (m *TraceHeader) XXX_Size() int {
+	return xxx_messageInfo_TraceHeader.Size(m)
+}
+func (m *TraceHeader) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceHeader.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceHeader proto.InternalMessageInfo
+
+func (m *TraceHeader) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *TraceHeader) GetRunName() string {
+	if m != nil {
+		return m.RunName
+	}
+	return ""
+}
+
+func (m *TraceHeader) GetDataSetName() string {
+	if m != nil {
+		return m.DataSetName
+	}
+	return ""
+}
+
+func (m *TraceHeader) GetNbLines() int32 {
+	if m != nil {
+		return m.NbLines
+	}
+	return 0
+}
+
+func (m *TraceHeader) GetNbWriteThreads() int32 {
+	if m != nil {
+		return m.NbWriteThreads
+	}
+	return 0
+}
+
+func (m *TraceHeader) GetNbReadThreads() int32 {
+	if m != nil {
+		return m.NbReadThreads
+	}
+	return 0
+}
+
+func (m *TraceHeader) GetSeed() int64 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
+}
+
+type TraceOp struct {
+	Thread               int32       `protobuf:"varint,1,opt,name=thread,proto3" json:"thread,omitempty"`
+	Op                   TraceOpType `protobuf:"varint,2,opt,name=op,proto3,enum=maptester.TraceOpType" json:"op,omitempty"`
+	KeyIdx               int32       `protobuf:"varint,3,opt,name=keyIdx,proto3" json:"keyIdx,omitempty"`
+	Miss                 bool        `protobuf:"varint,4,opt,name=miss,proto3" json:"miss,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *TraceOp) Reset()         { *m = TraceOp{} }
+func (m *TraceOp) String() string { return proto.CompactTextString(m) }
+func (*TraceOp) ProtoMessage()    {}
+func (*TraceOp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_40c4782d007dfce9, []int{6}
+}
+
+func (m *TraceOp) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TraceOp.Unmarshal(m, b)
+}
+func (m *TraceOp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TraceOp.Marshal(b, m, deterministic)
+}
+func (m *TraceOp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceOp.Merge(m, src)
+}
+func (m *TraceOp) XXX_Size() int {
+	return xxx_messageInfo_TraceOp.Size(m)
+}
+func (m *TraceOp) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceOp.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceOp proto.InternalMessageInfo
+
+func (m *TraceOp) GetThread() int32 {
+	if m != nil {
+		return m.Thread
+	}
+	return 0
+}
+
+func (m *TraceOp) GetOp() TraceOpType {
+	if m != nil {
+		return m.Op
+	}
+	return TraceOpType_LOAD
+}
+
+func (m *TraceOp) GetKeyIdx() int32 {
+	if m != nil {
+		return m.KeyIdx
+	}
+	return 0
+}
+
+func (m *TraceOp) GetMiss() bool {
+	if m != nil {
+		return m.Miss
+	}
+	return false
+}
+
 func init() {
+	proto.RegisterEnum("maptester.TraceOpType", TraceOpType_name, TraceOpType_value)
 	proto.RegisterType((*TestValue)(nil), "maptester.TestValue")
 	proto.RegisterType((*IntTestLine)(nil), "maptester.IntTestLine")
 	proto.RegisterType((*StringTestLine)(nil), "maptester.StringTestLine")
 	proto.RegisterType((*DataFileReport)(nil), "maptester.DataFileReport")
+	proto.RegisterType((*DataFileHeader)(nil), "maptester.DataFileHeader")
+	proto.RegisterType((*TraceHeader)(nil), "maptester.TraceHeader")
+	proto.RegisterType((*TraceOp)(nil), "maptester.TraceOp")
 }
 
 func init() {
@@ -244,22 +582,44 @@ func init() {
 }
 
 var fileDescriptor_40c4782d007dfce9 = []byte{
-	// 271 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x91, 0xc1, 0x4a, 0xc4, 0x30,
-	0x10, 0x86, 0xa9, 0xdd, 0x2a, 0x9d, 0x85, 0x65, 0x0d, 0x1e, 0x72, 0x10, 0x29, 0x3d, 0x95, 0x45,
-	0x0a, 0xea, 0x2b, 0xa8, 0x20, 0x2b, 0x2a, 0xd9, 0xb2, 0xf7, 0x84, 0x4e, 0x35, 0x6c, 0x9b, 0x96,
-	0x64, 0x14, 0xfb, 0x7a, 0x3e, 0x99, 0x24, 0xea, 0x2a, 0xf4, 0xe4, 0x6d, 0xf2, 0xcd, 0x3f, 0xdf,
-	0x04, 0x06, 0x16, 0x84, 0x8e, 0x6a, 0x49, 0xb2, 0x1c, 0x6c, 0x4f, 0x3d, 0x4b, 0x3b, 0x39, 0x78,
-	0x84, 0x36, 0xbf, 0x80, 0xb4, 0x42, 0x47, 0x5b, 0xd9, 0xbe, 0x22, 0x63, 0x30, 0x73, 0x5b, 0xd9,
-	0xf2, 0x28, 0x8b, 0x8a, 0x54, 0x84, 0x9a, 0x2d, 0x21, 0xd6, 0xf5, 0x3b, 0x3f, 0xc8, 0xa2, 0x22,
-	0x16, 0xbe, 0xcc, 0xd7, 0x30, 0xbf, 0x33, 0xe4, 0xa7, 0xee, 0xb5, 0x41, 0x1f, 0xd8, 0xe1, 0xc8,
-	0xa3, 0x2c, 0xf6, 0x81, 0x1d, 0x8e, 0x6c, 0x05, 0xc9, 0x9b, 0xf7, 0x85, 0xa1, 0xf9, 0xe5, 0x49,
-	0xb9, 0x5f, 0x57, 0xee, 0x77, 0x89, 0xaf, 0x48, 0xfe, 0x00, 0x8b, 0x0d, 0x59, 0x6d, 0x9e, 0xa7,
-	0x3e, 0xff, 0x87, 0x7f, 0xfb, 0x3e, 0x22, 0x58, 0x5c, 0x4b, 0x92, 0xb7, 0xba, 0x45, 0x81, 0x43,
-	0x6f, 0x89, 0x71, 0x38, 0x32, 0xca, 0xab, 0x5d, 0x90, 0x26, 0xe2, 0xe7, 0xc9, 0x4e, 0x21, 0x35,
-	0xea, 0xc6, 0x90, 0xd5, 0xe8, 0x82, 0x3c, 0x11, 0xbf, 0x80, 0x9d, 0x01, 0x18, 0xb5, 0x91, 0x1d,
-	0xae, 0x71, 0x74, 0x3c, 0x0e, 0xed, 0x3f, 0x84, 0xad, 0x60, 0x69, 0xd4, 0x63, 0x53, 0xe9, 0x0e,
-	0xdd, 0x37, 0xe4, 0xb3, 0x2c, 0x2e, 0x12, 0x31, 0xe1, 0xec, 0x1c, 0x8e, 0xfb, 0xa6, 0x71, 0x48,
-	0xee, 0x09, 0x6d, 0xf5, 0x62, 0x51, 0xd6, 0x8e, 0x27, 0x21, 0x3c, 0x6d, 0xa8, 0xc3, 0x70, 0xa6,
-	0xab, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x26, 0x78, 0xc0, 0xb4, 0xb8, 0x01, 0x00, 0x00,
+	// 624 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xdd, 0x6a, 0xdb, 0x4c,
+	0x10, 0xfd, 0xfc, 0x23, 0xdb, 0x1a, 0x27, 0xfe, 0x9c, 0xa5, 0x0d, 0xa2, 0x94, 0x62, 0x44, 0x09,
+	0xc6, 0x94, 0x40, 0xd3, 0xbe, 0x40, 0x4b, 0x52, 0x1a, 0x12, 0xe2, 0xb0, 0x36, 0xe9, 0x65, 0x58,
+	0x59, 0xe3, 0x76, 0xb1, 0xb4, 0x12, 0xbb, 0xeb, 0x34, 0xee, 0x93, 0xf5, 0xb2, 0x6f, 0xd2, 0x57,
+	0x29, 0x3b, 0x92, 0x6d, 0x39, 0x29, 0x94, 0x5e, 0xf4, 0x6e, 0xe6, 0xcc, 0xd9, 0x59, 0xcd, 0x99,
+	0xa3, 0x85, 0x9e, 0x45, 0x63, 0x63, 0x61, 0xc5, 0x71, 0xae, 0x33, 0x9b, 0x31, 0x3f, 0x15, 0xb9,
+	0x83, 0x50, 0x87, 0xaf, 0xc1, 0x9f, 0xa2, 0xb1, 0x37, 0x22, 0x59, 0x22, 0x63, 0xd0, 0x34, 0x37,
+	0x22, 0x09, 0x6a, 0x83, 0xda, 0xd0, 0xe7, 0x14, 0xb3, 0x3e, 0x34, 0x64, 0x7c, 0x1f, 0xd4, 0x07,
+	0xb5, 0x61, 0x83, 0xbb, 0x30, 0xbc, 0x80, 0xee, 0xb9, 0xb2, 0xee, 0xd4, 0xa5, 0x54, 0xe8, 0x08,
+	0x0b, 0x5c, 0x05, 0xb5, 0x41, 0xc3, 0x11, 0x16, 0xb8, 0x62, 0x23, 0xf0, 0xee, 0x5c, 0x3f, 0x3a,
+	0xd4, 0x3d, 0x79, 0x72, 0xbc, 0xb9, 0xee, 0x78, 0x73, 0x17, 0x2f, 0x28, 0xe1, 0x15, 0xf4, 0x26,
+	0x56, 0x4b, 0xf5, 0xf9, 0x71, 0x3f, 0xf7, 0x0d, 0x7f, 0xdd, 0xef, 0x47, 0x03, 0x7a, 0xa7, 0xc2,
+	0x8a, 0x0f, 0x32, 0x41, 0x8e, 0x79, 0xa6, 0x2d, 0x0b, 0xa0, 0xad, 0x22, 0xd7, 0xda, 0x50, 0x53,
+	0x8f, 0xaf, 0x53, 0xf6, 0x1c, 0x7c, 0x15, 0x9d, 0x29, 0xab, 0x25, 0x1a, 0x6a, 0xee, 0xf1, 0x2d,
+	0xc0, 0x5e, 0x00, 0xa8, 0x68, 0x22, 0x52, 0xbc, 0xc0, 0x95, 0x09, 0x1a, 0x54, 0xae, 0x20, 0x6c,
+	0x04, 0x7d, 0x15, 0x8d, 0xe7, 0x53, 0x99, 0xa2, 0x29, 0xc1, 0xa0, 0x39, 0x68, 0x0c, 0x3d, 0xfe,
+	0x08, 0x67, 0xaf, 0xe0, 0x20, 0x9b, 0xcf, 0x0d, 0x5a, 0x73, 0x8d, 0x7a, 0xfa, 0x45, 0xa3, 0x88,
+	0x4d, 0xe0, 0x91, 0x64, 0x8f, 0x0b, 0xec, 0x19, 0x74, 0xe6, 0x32, 0xc1, 0x89, 0xfc, 0x86, 0x41,
+	0x8b, 0x84, 0xdf, 0xe4, 0x6e, 0x1a, 0x2d, 0xbe, 0x52, 0xa9, 0x4d, 0xa5, 0x75, 0xca, 0x06, 0xd0,
+	0x9d, 0x65, 0x69, 0xae, 0xd1, 0x18, 0x99, 0xa9, 0xa0, 0x43, 0x02, 0x56, 0x21, 0xf6, 0x16, 0x9e,
+	0x92, 0x4a, 0x8e, 0x7e, 0x2a, 0x8d, 0xd5, 0x32, 0x5a, 0x5a, 0xc7, 0xf5, 0x89, 0xfb, 0xfb, 0x22,
+	0x0b, 0x61, 0x2f, 0x95, 0xea, 0x66, 0x5d, 0x0b, 0x80, 0x94, 0xd8, 0xc1, 0x88, 0x23, 0xee, 0xb7,
+	0x9c, 0x6e, 0xc9, 0xa9, 0x60, 0xec, 0x08, 0x7a, 0x36, 0xb3, 0x22, 0xd9, 0xb2, 0xf6, 0x68, 0x80,
+	0x07, 0x68, 0xf8, 0xbd, 0xbe, 0x5d, 0xe1, 0x47, 0x14, 0x31, 0x6a, 0x37, 0xf4, 0x1d, 0x6a, 0x1a,
+	0xab, 0x5c, 0x61, 0x99, 0x92, 0x65, 0x11, 0xe3, 0xd2, 0x9f, 0x14, 0x3b, 0xf6, 0x02, 0x57, 0xd3,
+	0x55, 0x8e, 0xb4, 0x35, 0x9f, 0xaf, 0x53, 0xf6, 0x12, 0xf6, 0x67, 0x99, 0x9a, 0x27, 0x72, 0x66,
+	0xb9, 0xb0, 0x32, 0x0b, 0x9a, 0x83, 0xda, 0xb0, 0xce, 0x77, 0x41, 0x67, 0x8b, 0x8d, 0x12, 0x81,
+	0x57, 0xd8, 0x62, 0x03, 0x54, 0xed, 0xd4, 0xda, 0xb5, 0xd3, 0x10, 0xfe, 0x2f, 0xc3, 0x6b, 0xd4,
+	0xef, 0x93, 0x6c, 0xb6, 0xa0, 0x15, 0x79, 0xfc, 0x21, 0xfc, 0xaf, 0x56, 0x15, 0xfe, 0xac, 0x41,
+	0x77, 0xaa, 0xc5, 0xec, 0xcf, 0xba, 0x39, 0x1b, 0x2d, 0xd5, 0x95, 0x48, 0x8b, 0xbf, 0xca, 0xe7,
+	0xeb, 0xd4, 0x7d, 0x9b, 0x7b, 0x2a, 0x26, 0x68, 0xa9, 0x5a, 0x28, 0x58, 0x85, 0xaa, 0x0a, 0x34,
+	0x77, 0x15, 0x38, 0x82, 0x9e, 0x8a, 0x3e, 0x69, 0x69, 0x71, 0xeb, 0x71, 0x47, 0x78, 0x80, 0xba,
+	0x3d, 0xa8, 0x88, 0xa3, 0x88, 0xd7, 0xb4, 0x42, 0xc9, 0x5d, 0x70, 0xb3, 0xdb, 0xf6, 0x76, 0xb7,
+	0xe1, 0x12, 0xda, 0x34, 0xe0, 0x38, 0x67, 0x87, 0xd0, 0xb2, 0xc4, 0x2c, 0x67, 0x2b, 0x33, 0x76,
+	0x04, 0xf5, 0x2c, 0xa7, 0xa9, 0x7a, 0x27, 0x87, 0xd5, 0xb7, 0xa2, 0x38, 0xe7, 0x8c, 0xc0, 0xeb,
+	0x19, 0x9d, 0x5f, 0xe0, 0xea, 0x3c, 0xbe, 0x2f, 0xff, 0xed, 0x32, 0x73, 0xd7, 0xa6, 0xd2, 0x14,
+	0xb3, 0x75, 0x38, 0xc5, 0xa3, 0x11, 0x74, 0x2b, 0xc7, 0x59, 0x07, 0x9a, 0x97, 0xe3, 0x77, 0xa7,
+	0xfd, 0xff, 0xd8, 0x01, 0xec, 0xbb, 0xe8, 0x76, 0xcc, 0x6f, 0x27, 0xd3, 0x31, 0x3f, 0xeb, 0xd7,
+	0xa2, 0x16, 0x3d, 0xb2, 0x6f, 0x7e, 0x0d, 0x00, 0x1b, 0xc4, 0xcc, 0x52, 0x76, 0x05, 0x00, 0x00,
 }
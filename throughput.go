@@ -0,0 +1,122 @@
+package maptester
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TestDuration runs each test for a fixed wall-clock duration on a populated map
+// instead of a fixed amount of work, when not 0.
+var TestDuration = time.Duration(0)
+
+// Threads running until stopped check the stop flag every checkStopEvery operations
+const checkStopEvery = 64
+
+func (mp *MapPerfTestResult) isStopped(nbOps int) bool {
+	return nbOps%checkStopEvery == 0 && atomic.LoadUint32(&mp.stopRequested) > 0
+}
+
+// runFor releases the threads of the barrier to run for the test duration, then stops them
+func (mp *MapPerfTestResult) runFor(barrier *startBarrier, wg *sync.WaitGroup) time.Duration {
+	start := barrier.release()
+	time.Sleep(TestDuration)
+	atomic.StoreUint32(&mp.stopRequested, 1)
+	wg.Wait()
+	return time.Since(start)
+}
+
+// nbReadDone returns the number of operations done by the read threads
+func (mp *MapPerfTestResult) nbReadDone() int {
+	if mp.targetDuration > 0 {
+		return int(mp.readOpsDone + mp.mixedOpsDone)
+	}
+	conf := mp.runConf.testConf
+	return conf.nbReadTest * conf.nbReadThreads
+}
+
+// testThroughput populates the map, then for the test duration the read threads
+// load random keys while, in the interleaved scenario, the write threads
+// overwrite random lines. The populating is not measured.
+func (mp *MapPerfTestResult) testThroughput(m ConcurrentInt3Map, im *IntMapTestDataSet) {
+	conf := mp.runConf.testConf
+	wg := new(sync.WaitGroup)
+	nbWriteThreads := conf.nbWriteThreads
+	if !m.SupportConcurrentWrite() {
+		nbWriteThreads = 1
+	}
+	populateBarrier := newStartBarrier(nbWriteThreads)
+	wg.Add(nbWriteThreads)
+	for i := 0; i < nbWriteThreads; i++ {
+		offset, size := writeSegment(im.size, nbWriteThreads, i)
+		mp.startWorker(populateBarrier, true, wg, func() {
+			testLoadAndStore(m, im, offset, size, nil, mp)
+		})
+	}
+	populateBarrier.release()
+	wg.Wait()
+	if mp.runConf.scenario != InterleavedScenario || !m.SupportConcurrentWrite() {
+		// Read only
+		nbWriteThreads = 0
+	}
+	seeds := make([]int64, nbWriteThreads)
+	for i := range seeds {
+		seeds[i] = rand.Int63()
+	}
+	mp.harness = mp.harnessMode()
+	rnds := readerRands(mp.harness, conf.nbReadThreads)
+
+	barrier := newStartBarrier(nbWriteThreads + conf.nbReadThreads)
+	doneWriting := uint32(1)
+	wg.Add(nbWriteThreads + conf.nbReadThreads)
+	for i := 0; i < nbWriteThreads; i++ {
+		seed := seeds[i]
+		mp.startWorker(barrier, true, wg, func() {
+			testUpdate(m, im, seed, mp)
+		})
+	}
+	for i := 0; i < conf.nbReadThreads; i++ {
+		rnd := rnds[i]
+		mp.startWorker(barrier, false, wg, func() {
+			testLoad(m, im, -1, nil, nil, rnd, &doneWriting, mp)
+		})
+	}
+	barrier.waitReady()
+
+	mp.init()
+	mp.targetDuration = TestDuration
+	elapsed := mp.runFor(barrier, wg)
+	if nbWriteThreads > 0 {
+		mp.phases.setWrite(int(mp.writeOpsDone), elapsed)
+	}
+	mp.phases.setRead(int(mp.readOpsDone), elapsed)
+
+	mp.nbMapEntries = m.Size()
+	mp.stop()
+	mp.measureHeap(m)
+	mp.display(mp.Name())
+}
+
+// testUpdate overwrites the values of random lines of the populated map until the test is stopped
+func testUpdate(m ConcurrentInt3Map, im *IntMapTestDataSet, seed int64, perf *MapPerfTestResult) {
+	errorsKeyNotFound := int32(0)
+	rnd := rand.New(rand.NewSource(seed))
+	stores := new(latencyHistogram)
+	nbOps := 0
+	for ; !perf.isStopped(nbOps); nbOps++ {
+		idx := rnd.Intn(im.size)
+		val := &im.values[idx]
+		start := stores.start(nbOps)
+		oldValue, loaded := m.LoadOrStore(im.keys[idx], &TestMapValue{val: val})
+		stores.end(start)
+		if !loaded {
+			errorsKeyNotFound++
+		} else if oldValue.value() != val {
+			oldValue.overwriteVal(val)
+		}
+	}
+	perf.latencies.add(nil, stores)
+	atomic.AddInt64(&perf.writeOpsDone, int64(nbOps))
+	atomic.AddInt32(&perf.errorsKeyNotFound, errorsKeyNotFound)
+}
@@ -0,0 +1,36 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestThroughput(t *testing.T) {
+	defer func(d time.Duration) { TestDuration = d }(TestDuration)
+	TestDuration = 50 * time.Millisecond
+	size := 1001
+	im, report := newTestDataSet(size)
+
+	for _, scenario := range []string{InterleavedScenario, PhasedScenario} {
+		rc := newTestRunConfiguration(size, 3, 2, 500)
+		rc.scenario = scenario
+		perf := &MapPerfTestResult{runConf: rc, mapTypeName: "RWMutex"}
+		perf.fill(report)
+		perf.testConcurrentMap(im)
+		assert.Equal(t, 0, perf.NbErrors(), scenario)
+		assert.Equal(t, int(report.NbEntries), perf.nbMapEntries, scenario)
+		assert.Equal(t, TestDuration, perf.targetDuration, scenario)
+		assert.True(t, perf.phases.readThroughput() > 0, scenario)
+		assert.Equal(t, scenario == InterleavedScenario, perf.phases.writeThroughput() > 0, scenario)
+	}
+
+	rc := newTestRunConfiguration(size, 3, 2, 500)
+	rc.mix = YcsbMixes[0]
+	rc.scenario = SteadyScenario
+	perf := &MapPerfTestResult{runConf: rc, mapTypeName: "syncMap"}
+	perf.fill(report)
+	perf.testConcurrentMap(im)
+	assert.Equal(t, 0, perf.NbErrors())
+	assert.True(t, perf.phases.mixedThroughput() > 0)
+}
@@ -0,0 +1,323 @@
+package maptester
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/freddy33/maptester/utils"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/logger"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// Trace file format: Magic, version byte, varint size prefixed TraceHeader,
+// then varint size prefixed TraceOp records. The operations of a thread are in
+// execution order, the threads can be interleaved.
+const (
+	TraceFileMagic   = "MAPO"
+	TraceFileVersion = 1
+	TraceFileSuffix  = ".trace"
+	MaxTraceOpSize   = 64
+)
+
+// Record the operations of each test run in a trace file
+var RecordTraces = false
+
+type traceOp struct {
+	op     TraceOpType
+	keyIdx int32
+	miss   bool
+}
+
+// OpTrace holds the operations of all the threads of a test, write threads first
+type OpTrace struct {
+	header  *TraceHeader
+	threads [][]traceOp
+}
+
+func getTraceFilename(name string) string {
+	return filepath.Join(utils.GetTracesDir(), name+TraceFileSuffix)
+}
+
+// writeSegment returns the lines inserted by a write thread, the last thread taking the remainder
+func writeSegment(nbLines, nbWriteThreads, thread int) (offset, size int) {
+	size = nbLines / nbWriteThreads
+	offset = size * thread
+	if thread == nbWriteThreads-1 {
+		size = nbLines - offset
+	}
+	return offset, size
+}
+
+func newOpTrace(rc *RunConfiguration, nbLines int, seed int64) *OpTrace {
+	conf := rc.testConf
+	t := new(OpTrace)
+	t.header = &TraceHeader{
+		Version:        TraceFileVersion,
+		RunName:        rc.GetRunName(),
+		DataSetName:    rc.dataConf.GetDataSetName(),
+		NbLines:        int32(nbLines),
+		NbWriteThreads: int32(conf.nbWriteThreads),
+		NbReadThreads:  int32(conf.nbReadThreads),
+		Seed:           seed,
+	}
+	t.threads = make([][]traceOp, conf.nbWriteThreads+conf.nbReadThreads)
+	for i := 0; i < conf.nbWriteThreads; i++ {
+		offset, size := writeSegment(nbLines, conf.nbWriteThreads, i)
+		ops := make([]traceOp, size)
+		for j := range ops {
+			ops[j] = traceOp{op: TraceOpType_LOAD_OR_STORE, keyIdx: int32(offset + j)}
+		}
+		t.threads[i] = ops
+	}
+	return t
+}
+
+// newRecordingTrace prepares a trace where the read threads record their random operations
+func newRecordingTrace(rc *RunConfiguration, nbLines int) *OpTrace {
+	t := newOpTrace(rc, nbLines, 0)
+	conf := rc.testConf
+	for i := 0; i < conf.nbReadThreads; i++ {
+		t.threads[conf.nbWriteThreads+i] = make([]traceOp, conf.nbReadTest)
+	}
+	return t
+}
+
+// GenerateTrace creates offline the operations of a run configuration from the seed
+func GenerateTrace(rc *RunConfiguration, nbLines int, seed int64) *OpTrace {
+	t := newOpTrace(rc, nbLines, seed)
+	conf := rc.testConf
+	rnd := rand.New(rand.NewSource(seed))
+	for i := 0; i < conf.nbReadThreads; i++ {
+		t.threads[conf.nbWriteThreads+i] = generateReadOps(rnd, nbLines, conf.nbReadTest, conf.percentMiss)
+	}
+	return t
+}
+
+func (t *OpTrace) writeOps(thread int) []traceOp {
+	return t.threads[thread]
+}
+
+func (t *OpTrace) readOps(thread int) []traceOp {
+	return t.threads[int(t.header.NbWriteThreads)+thread]
+}
+
+func (t *OpTrace) nbOps() int {
+	total := 0
+	for _, ops := range t.threads {
+		total += len(ops)
+	}
+	return total
+}
+
+// check verifies the trace can be replayed for the run configuration on the data set
+func (t *OpTrace) check(rc *RunConfiguration, im *IntMapTestDataSet) error {
+	if t.header.RunName != rc.GetRunName() {
+		return fmt.Errorf("trace of run %s cannot replay run %s", t.header.RunName, rc.GetRunName())
+	}
+	if int(t.header.NbLines) != im.size {
+		return fmt.Errorf("trace is for %d lines but data set has %d", t.header.NbLines, im.size)
+	}
+	if int(t.header.NbWriteThreads) != rc.testConf.nbWriteThreads || int(t.header.NbReadThreads) != rc.testConf.nbReadThreads {
+		return fmt.Errorf("trace has %d write and %d read threads, expected %d and %d",
+			t.header.NbWriteThreads, t.header.NbReadThreads, rc.testConf.nbWriteThreads, rc.testConf.nbReadThreads)
+	}
+	return nil
+}
+
+func writeTraceFile(filename string, t *OpTrace) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0665)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriterSize(file, DataFileBufferSize)
+	varintBuf := make([]byte, binary.MaxVarintLen64)
+	writePrefixed := func(msg proto.Message) error {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		n := binary.PutUvarint(varintBuf, uint64(len(data)))
+		_, err = out.Write(varintBuf[:n])
+		if err == nil {
+			_, err = out.Write(data)
+		}
+		return err
+	}
+	_, err = out.WriteString(TraceFileMagic)
+	if err == nil {
+		err = out.WriteByte(TraceFileVersion)
+	}
+	if err == nil {
+		err = writePrefixed(t.header)
+	}
+	op := new(TraceOp)
+	for thread, ops := range t.threads {
+		for j := 0; j < len(ops) && err == nil; j++ {
+			op.Thread = int32(thread)
+			op.Op = ops[j].op
+			op.KeyIdx = ops[j].keyIdx
+			op.Miss = ops[j].miss
+			err = writePrefixed(op)
+		}
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	utils.CloseFile(file)
+	if err != nil {
+		utils.DeleteFile(filename)
+	}
+	return err
+}
+
+func readTraceFile(filename string) (*OpTrace, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CloseFile(file)
+	in := bufio.NewReaderSize(file, DataFileBufferSize)
+	readPrefixed := func(maxSize uint64, msg proto.Message) error {
+		size, err := binary.ReadUvarint(in)
+		if err != nil {
+			return err
+		}
+		if size > maxSize {
+			return fmt.Errorf("record of %d bytes is too big", size)
+		}
+		data := make([]byte, size)
+		_, err = io.ReadFull(in, data)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		return proto.Unmarshal(data, msg)
+	}
+
+	magic := make([]byte, len(TraceFileMagic)+1)
+	_, err = io.ReadFull(in, magic)
+	if err != nil || string(magic[:len(TraceFileMagic)]) != TraceFileMagic {
+		return nil, fmt.Errorf("%s is not a trace file", filename)
+	}
+	if magic[len(TraceFileMagic)] != TraceFileVersion {
+		return nil, fmt.Errorf("trace file %s version %d not supported", filename, magic[len(TraceFileMagic)])
+	}
+	t := new(OpTrace)
+	t.header = new(TraceHeader)
+	err = readPrefixed(MaxDataBlockSize, t.header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace file %s header: %v", filename, unexpectedEOF(err))
+	}
+	nbWriteThreads := int(t.header.NbWriteThreads)
+	t.threads = make([][]traceOp, nbWriteThreads+int(t.header.NbReadThreads))
+	op := new(TraceOp)
+	for nbOps := 0; ; nbOps++ {
+		err = readPrefixed(MaxTraceOpSize, op)
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace file %s op %d: %v", filename, nbOps, err)
+		}
+		thread := int(op.Thread)
+		if thread < 0 || thread >= len(t.threads) || op.KeyIdx < 0 || op.KeyIdx >= t.header.NbLines {
+			return nil, fmt.Errorf("invalid trace file %s op %d: %v", filename, nbOps, op)
+		}
+		if (thread < nbWriteThreads) != (op.Op == TraceOpType_LOAD_OR_STORE) {
+			return nil, fmt.Errorf("invalid trace file %s op %d: %s in thread %d", filename, nbOps, op.Op, thread)
+		}
+		t.threads[thread] = append(t.threads[thread], traceOp{op: op.Op, keyIdx: op.KeyIdx, miss: op.Miss})
+	}
+}
+
+// GenerateTraceFile writes the trace of the run configuration generated from the seed
+func GenerateTraceFile(runName string, seed int64, filename string) error {
+	rc, ok := RunConfigurations[runName]
+	if !ok {
+		return fmt.Errorf("run configuration %s not found", runName)
+	}
+	if filename == "" {
+		filename = getTraceFilename(fmt.Sprintf("%s-s%d", runName, seed))
+	}
+	t := GenerateTrace(rc, rc.dataConf.size, seed)
+	err := writeTraceFile(filename, t)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated trace %s with %d operations\n", filename, t.nbOps())
+	return nil
+}
+
+// ReplayTrace runs the operations of the trace file on each selected map type.
+// All map types get exactly the same per thread operations.
+func ReplayTrace(filename string, mapTypeNames []string) bool {
+	t, err := readTraceFile(filename)
+	if err != nil {
+		logger.Errorf("Cannot read trace due to %v", err)
+		return false
+	}
+	rc, ok := RunConfigurations[t.header.RunName]
+	if !ok {
+		logger.Errorf("Run configuration %s of trace %s not found, check the data sizes", t.header.RunName, filename)
+		return false
+	}
+	dc := rc.dataConf
+	im, report := ReadIntData(dc.GetDataFileName(), dc.size)
+	if im == nil {
+		logger.Errorf("Cannot replay trace %s since data set %s cannot be read", filename, dc.GetDataSetName())
+		return false
+	}
+	defer im.close()
+	err = t.check(rc, im)
+	if err != nil {
+		logger.Errorf("Cannot replay trace %s due to %v", filename, err)
+		return false
+	}
+
+	perfTests := make([]*MapPerfTestResult, 0, len(MapTypes))
+	for _, mt := range MapTypes {
+		if len(mapTypeNames) > 0 && !containsString(mapTypeNames, mt.name) {
+			continue
+		}
+		if !mt.supports(rc) {
+			logger.Infof("Map type %s skipped since it does not support run %s", mt.name, rc.GetRunName())
+			continue
+		}
+		perfTests = append(perfTests, &MapPerfTestResult{runConf: rc, mapTypeName: mt.name, trace: t})
+	}
+	csvResultFile := openCsvFile(len(perfTests))
+	defer utils.CloseFile(csvResultFile)
+	fmt.Printf("Replaying %d operations of %s on %d map types\n", t.nbOps(), filename, len(perfTests))
+	allPass := true
+	for idx, perfTest := range perfTests {
+		perfTest.fill(report)
+		perfTest.runRepetitions(im)
+		if perfTest.totalErrors() > 0 {
+			allPass = false
+		}
+		perfTest.dumpPerfData(idx, csvResultFile)
+	}
+	return allPass
+}
+
+// saveTrace writes the operations recorded during the test
+func (mp *MapPerfTestResult) saveTrace() {
+	filename := getTraceFilename(mp.Name())
+	err := writeTraceFile(filename, mp.trace)
+	if err != nil {
+		logger.Errorf("Cannot write trace %s due to %v", filename, err)
+	}
+	mp.trace = nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
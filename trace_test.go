@@ -0,0 +1,119 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+// raceDetector is set with -race. The fredMap loads its entries without atomic reads,
+// so the tests running it concurrently are not race clean and use another map with -race.
+var raceDetector = false
+
+func newTestDataSet(size int) (*IntMapTestDataSet, *DataFileReport) {
+	gen := newIntDataGenerator(size, 0.5, FixedValueSizeDistribution(12), 7)
+	im := &IntMapTestDataSet{size: size, keys: make([]Int3Key, size), values: make([]TestValue, size)}
+	keys := make(map[Int3Key]bool, size)
+	for i := 0; i < size; i++ {
+		_, key, value := gen.line(i)
+		im.keys[i] = key
+		im.values[i] = value
+		keys[key] = true
+	}
+	report := &DataFileReport{NbLines: int32(size), NbEntries: int32(len(keys)), NbSameKeys: int32(size - len(keys))}
+	return im, report
+}
+
+func newTestRunConfiguration(size, nbWriteThreads, nbReadThreads, nbReadTest int) *RunConfiguration {
+	dc := &DataConfiguration{keyType: KeyTypes[0], conflictRatio: 0.5, valueSize: FixedValueSizeDistribution(12), size: size}
+	dc.fillDataFileName()
+	rc := &RunConfiguration{
+		dataConf: dc,
+		mix:      PopulateMix,
+		scenario: InterleavedScenario,
+		runtime:  runtimeSettings{goMaxProcs: DefaultGoMaxProcs, gcPercent: DefaultGCPercent},
+		testConf: &MapTestConf{
+			nbWriteThreads: nbWriteThreads,
+			nbReadThreads:  nbReadThreads,
+			nbReadTest:     nbReadTest,
+			initRatio:      0.5,
+			percentMiss:    0.25,
+		},
+	}
+	rc.fillRunName()
+	return rc
+}
+
+func TestWriteSegment(t *testing.T) {
+	total := 0
+	for i := 0; i < 3; i++ {
+		offset, size := writeSegment(100, 3, i)
+		assert.Equal(t, total, offset)
+		total += size
+	}
+	assert.Equal(t, 100, total)
+}
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	size := 1001
+	im, report := newTestDataSet(size)
+	rc := newTestRunConfiguration(size, 3, 2, 500)
+
+	recordedMapType := "fredMap"
+	if raceDetector {
+		recordedMapType = "syncMap"
+	}
+	recorded := &MapPerfTestResult{runConf: rc, mapTypeName: recordedMapType, recordTrace: true}
+	recorded.fill(report)
+	recorded.testConcurrentMap(im)
+	assert.Equal(t, 0, recorded.NbErrors())
+	assert.Equal(t, size+2*500, recorded.trace.nbOps())
+
+	filename := filepath.Join(t.TempDir(), "recorded.trace")
+	assert.NoError(t, writeTraceFile(filename, recorded.trace))
+	trace, err := readTraceFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, recorded.trace.header.String(), trace.header.String())
+	assert.Equal(t, recorded.trace.threads, trace.threads)
+	assert.NoError(t, trace.check(rc, im))
+
+	for _, mapTypeName := range []string{"RWMutex", "syncMap"} {
+		replayed := &MapPerfTestResult{runConf: rc, mapTypeName: mapTypeName, trace: trace}
+		replayed.fill(report)
+		replayed.testConcurrentMap(im)
+		assert.Equal(t, 0, replayed.NbErrors())
+		assert.Equal(t, int(report.NbEntries), replayed.nbMapEntries)
+	}
+
+	other := newTestRunConfiguration(size, 2, 2, 500)
+	assert.Error(t, trace.check(other, im))
+}
+
+func TestGenerateTrace(t *testing.T) {
+	rc := newTestRunConfiguration(100, 2, 3, 50)
+	trace := GenerateTrace(rc, 100, 5)
+	assert.Equal(t, GenerateTrace(rc, 100, 5).threads, trace.threads)
+	assert.NotEqual(t, GenerateTrace(rc, 100, 6).threads, trace.threads)
+	assert.Equal(t, 100+3*50, trace.nbOps())
+	nbMiss := 0
+	for i := 0; i < 3; i++ {
+		for _, op := range trace.readOps(i) {
+			assert.Equal(t, TraceOpType_LOAD, op.op)
+			if op.miss {
+				nbMiss++
+			}
+		}
+	}
+	assert.True(t, nbMiss > 0 && nbMiss < 3*50)
+	assert.Equal(t, int32(50), trace.writeOps(1)[0].keyIdx)
+}
+
+func TestReadInvalidTraceFile(t *testing.T) {
+	rc := newTestRunConfiguration(100, 1, 1, 10)
+	trace := GenerateTrace(rc, 100, 5)
+	trace.threads[1][3].op = TraceOpType_LOAD_OR_STORE
+	filename := filepath.Join(t.TempDir(), "invalid.trace")
+	assert.NoError(t, writeTraceFile(filename, trace))
+	_, err := readTraceFile(filename)
+	assert.Error(t, err)
+}
@@ -2,12 +2,15 @@ package utils
 
 import (
 	"bufio"
+	"fmt"
 	"github.com/google/logger"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 var Verbose = false
@@ -56,24 +59,31 @@ func AbsPath(dir string) string {
 }
 
 func GetGitRootDir() string {
-	absPath := AbsPath(".")
-	p := absPath
+	p, ok := FindGitRootDir()
+	if !ok {
+		Log.Fatalf("did not find path with git under %s", AbsPath("."))
+		return ""
+	}
+	return p
+}
+
+// FindGitRootDir returns the git checkout of the current dir, false if not in a git checkout
+func FindGitRootDir() (string, bool) {
+	p := AbsPath(".")
 	// Check first if we are below the checkout dir
 	if b, p := DirExists(p, "qsm-go"); b {
 		if b, _ = DirExists(p, ".git"); b {
-			return p
-		} else {
-			Log.Fatalf("found qsm-go sub folder at %s which not a git checkout", p)
-			return ""
+			return p, true
 		}
+		Log.Errorf("found qsm-go sub folder at %s which not a git checkout", p)
+		return "", false
 	}
 	for {
 		if p == "." || p == "/" {
-			Log.Fatalf("did not find path with git under %s", absPath)
-			return ""
+			return "", false
 		}
 		if b, _ := DirExists(p, ".git"); b {
-			return p
+			return p, true
 		}
 		p = filepath.Dir(p)
 	}
@@ -132,30 +142,33 @@ func GetOutPerfDir() string {
 	return getOrCreateBuildSubDir("perf")
 }
 
+func GetTracesDir() string {
+	return getOrCreateBuildSubDir("traces")
+}
+
 func ExitOnError(err error) {
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-var lengthByte = make([]byte, 1)
-
 func WriteDataBlock(file *os.File, bytes []byte) int {
 	n, err := file.Write(bytes)
 	ExitOnError(err)
 	return n
 }
 
-func WriteDataBlockPrefixSize(file *os.File, bytes []byte) byte {
+func WriteDataBlockPrefixSize(w io.Writer, bytes []byte) byte {
 	l := len(bytes)
 	if l > 255 {
-		logger.Errorf("Cannot write block bigger than 255. It is %d", l)
+		logger.Fatalf("Cannot write block bigger than 255. It is %d", l)
 		return 0
 	}
-	lengthByte[0] = byte(l)
-	_, err := file.Write(lengthByte)
+	// Local length buffer since data files are written in parallel
+	lengthByte := []byte{byte(l)}
+	_, err := w.Write(lengthByte)
 	ExitOnError(err)
-	_, err = file.Write(bytes)
+	_, err = w.Write(bytes)
 	ExitOnError(err)
 	return lengthByte[0]
 }
@@ -195,3 +208,52 @@ func Float32EqualEpsilon(a, b float32, epsilon float32) bool {
 	delta := a - b
 	return (delta >= 0.0 && delta < epsilon) || (delta < 0.0 && delta > -epsilon)
 }
+
+// ParseSizes parses a comma separated list of sizes accepting K, M and G multipliers
+func ParseSizes(value string) ([]int, error) {
+	parts := strings.Split(value, ",")
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		multiplier := 1
+		switch part[len(part)-1] {
+		case 'k', 'K':
+			multiplier = 1000
+		case 'm', 'M':
+			multiplier = 1000 * 1000
+		case 'g', 'G':
+			multiplier = 1000 * 1000 * 1000
+		}
+		if multiplier > 1 {
+			part = part[:len(part)-1]
+		}
+		size, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q in %q: %v", part, value, err)
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("size %q in %q should be positive", part, value)
+		}
+		result = append(result, size*multiplier)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no sizes in %q", value)
+	}
+	return result, nil
+}
+
+// FormatSize writes a size with the largest K, M or G multiplier dividing it, the inverse of ParseSizes
+func FormatSize(size int64) string {
+	for _, m := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"G", 1000 * 1000 * 1000}, {"M", 1000 * 1000}, {"K", 1000}} {
+		if size >= m.multiplier && size%m.multiplier == 0 {
+			return fmt.Sprintf("%d%s", size/m.multiplier, m.suffix)
+		}
+	}
+	return fmt.Sprintf("%d", size)
+}
@@ -0,0 +1,118 @@
+package maptester
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Value size distribution kinds, and the size all drawn value sizes are capped to
+const (
+	FixedValueSize     = "fixed"
+	UniformValueSize   = "uniform"
+	LogNormalValueSize = "lognormal"
+	BimodalValueSize   = "bimodal"
+	MaxValueSize       = 64 * 1024
+)
+
+// ValueSizeDistribution draws the size of the value strings of a data set.
+// Its name, used in the data configuration name, is one of:
+// v12 for fixed size 12, vu16-1024 for uniform sizes between 16 and 1024,
+// vl128-s10 for log-normal with median 128 and sigma 1.0,
+// vb16-4096-p10 for bimodal with 10% of size 4096 and the others of size 16.
+type ValueSizeDistribution struct {
+	Kind string
+	// The fixed size, the uniform min, the log-normal median or the bimodal small size
+	Size int
+	// The uniform max or the bimodal large size
+	MaxSize int
+	// Log-normal sigma in tenths
+	Sigma int
+	// Bimodal percent of large values
+	LargePercent int
+}
+
+func FixedValueSizeDistribution(size int) ValueSizeDistribution {
+	return ValueSizeDistribution{Kind: FixedValueSize, Size: size}
+}
+
+func (d ValueSizeDistribution) Name() string {
+	switch d.Kind {
+	case UniformValueSize:
+		return fmt.Sprintf("vu%d-%d", d.Size, d.MaxSize)
+	case LogNormalValueSize:
+		return fmt.Sprintf("vl%d-s%02d", d.Size, d.Sigma)
+	case BimodalValueSize:
+		return fmt.Sprintf("vb%d-%d-p%02d", d.Size, d.MaxSize, d.LargePercent)
+	default:
+		return fmt.Sprintf("v%02d", d.Size)
+	}
+}
+
+func (d ValueSizeDistribution) String() string {
+	return d.Name()
+}
+
+// ParseValueSizeDistribution returns the distribution of the name
+func ParseValueSizeDistribution(name string) (ValueSizeDistribution, error) {
+	d := ValueSizeDistribution{}
+	var err error
+	if len(name) < 2 || name[0] != 'v' {
+		return d, fmt.Errorf("value size distribution %q should start with v", name)
+	}
+	switch name[1] {
+	case 'u':
+		d.Kind = UniformValueSize
+		_, err = fmt.Sscanf(name, "vu%d-%d", &d.Size, &d.MaxSize)
+	case 'l':
+		d.Kind = LogNormalValueSize
+		_, err = fmt.Sscanf(name, "vl%d-s%d", &d.Size, &d.Sigma)
+	case 'b':
+		d.Kind = BimodalValueSize
+		_, err = fmt.Sscanf(name, "vb%d-%d-p%d", &d.Size, &d.MaxSize, &d.LargePercent)
+	default:
+		d.Kind = FixedValueSize
+		_, err = fmt.Sscanf(name, "v%d", &d.Size)
+	}
+	if err == nil {
+		err = d.validate()
+	}
+	if err == nil && d.Name() != name {
+		err = fmt.Errorf("should be written %s", d.Name())
+	}
+	if err != nil {
+		return d, fmt.Errorf("invalid value size distribution %q: %v", name, err)
+	}
+	return d, nil
+}
+
+func (d ValueSizeDistribution) validate() error {
+	if d.Size < 0 || d.Size > MaxValueSize || d.MaxSize > MaxValueSize {
+		return fmt.Errorf("sizes should be between 0 and %d", MaxValueSize)
+	}
+	if (d.Kind == UniformValueSize || d.Kind == BimodalValueSize) && d.MaxSize < d.Size {
+		return fmt.Errorf("max size %d smaller than %d", d.MaxSize, d.Size)
+	}
+	if d.LargePercent < 0 || d.LargePercent > 100 || d.Sigma < 0 {
+		return fmt.Errorf("percent and sigma should be positive")
+	}
+	return nil
+}
+
+// draw returns a value size. The fixed distribution does not use the random source.
+func (d ValueSizeDistribution) draw(rnd *rand.Rand) int {
+	switch d.Kind {
+	case UniformValueSize:
+		return d.Size + rnd.Intn(d.MaxSize-d.Size+1)
+	case LogNormalValueSize:
+		size := math.Round(float64(d.Size) * math.Exp(float64(d.Sigma)/10.0*rnd.NormFloat64()))
+		return int(math.Max(1.0, math.Min(size, MaxValueSize)))
+	case BimodalValueSize:
+		if rnd.Intn(100) < d.LargePercent {
+			return d.MaxSize
+		}
+		return d.Size
+	default:
+		return d.Size
+	}
+}
@@ -0,0 +1,70 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestParseValueSizeDistribution(t *testing.T) {
+	for _, name := range []string{"v12", "v05", "vu16-1024", "vl128-s10", "vb16-4096-p10"} {
+		d, err := ParseValueSizeDistribution(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, d.Name())
+	}
+	d, err := ParseValueSizeDistribution("vb16-4096-p10")
+	assert.NoError(t, err)
+	assert.Equal(t, ValueSizeDistribution{Kind: BimodalValueSize, Size: 16, MaxSize: 4096, LargePercent: 10}, d)
+	for _, name := range []string{"", "12", "v5", "vx12", "vu100-10", "vu16", "vl128", "vb16-4096-p200", "v12-extra", "v100000"} {
+		_, err := ParseValueSizeDistribution(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestValueSizeDistributionDraw(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	nbDraws := 10000
+	drawAll := func(name string) (int, int, float64) {
+		d, err := ParseValueSizeDistribution(name)
+		assert.NoError(t, err)
+		min, max, total := MaxValueSize, 0, 0
+		for i := 0; i < nbDraws; i++ {
+			size := d.draw(rnd)
+			if size < min {
+				min = size
+			}
+			if size > max {
+				max = size
+			}
+			total += size
+		}
+		return min, max, float64(total) / float64(nbDraws)
+	}
+	min, max, avg := drawAll("v12")
+	assert.Equal(t, []int{12, 12}, []int{min, max})
+	min, max, avg = drawAll("vu16-1024")
+	assert.True(t, min >= 16 && max <= 1024 && avg > 450 && avg < 590, "uniform %d %d %f", min, max, avg)
+	min, max, avg = drawAll("vb16-4096-p10")
+	assert.Equal(t, []int{16, 4096}, []int{min, max})
+	assert.True(t, avg > 350 && avg < 480, "bimodal avg %f", avg)
+	min, max, avg = drawAll("vl128-s10")
+	assert.True(t, min >= 1 && max <= MaxValueSize && max > 1024 && avg > 128, "log-normal %d %d %f", min, max, avg)
+}
+
+func TestIntDataGeneratorValueSizes(t *testing.T) {
+	size := 1000
+	d, err := ParseValueSizeDistribution("vu0-300")
+	assert.NoError(t, err)
+	gen := newIntDataGenerator(size, 0.5, d, 42)
+	other := newIntDataGenerator(size, 0.5, d, 42)
+	sizes := make(map[int]bool)
+	for i := 0; i < size; i++ {
+		_, _, value := gen.line(i)
+		_, _, otherValue := other.line(size - 1 - i)
+		_, _, otherValue = other.line(i)
+		assert.Equal(t, value.SVal, otherValue.SVal)
+		assert.True(t, len(value.SVal) <= 300)
+		sizes[len(value.SVal)] = true
+	}
+	assert.True(t, len(sizes) > 100, "only %d different sizes", len(sizes))
+}
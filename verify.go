@@ -0,0 +1,143 @@
+package maptester
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// DataSetCheck is one verified value of a data set
+type DataSetCheck struct {
+	Name     string      `json:"name"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	Pass     bool        `json:"pass"`
+}
+
+// DataSetVerification is the result of comparing a loaded data set with its stored report
+type DataSetVerification struct {
+	Name   string         `json:"name"`
+	Size   int            `json:"size"`
+	Pass   bool           `json:"pass"`
+	Checks []DataSetCheck `json:"checks"`
+}
+
+func (v *DataSetVerification) check(name string, expected, actual interface{}) {
+	pass := reflect.DeepEqual(expected, actual)
+	v.Checks = append(v.Checks, DataSetCheck{name, expected, actual, pass})
+	v.Pass = v.Pass && pass
+}
+
+func (v *DataSetVerification) Json() string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("{\"name\":%q,\"pass\":false,\"error\":%q}", v.Name, err.Error())
+	}
+	return string(data)
+}
+
+// Verify recomputes the whole report from the loaded data set and the data file,
+// and compares it field by field with the stored report.
+func Verify(name string, size int, im *IntMapTestDataSet, result *DataFileReport) *DataSetVerification {
+	v := &DataSetVerification{Name: name, Size: size, Pass: true, Checks: make([]DataSetCheck, 0, 10)}
+	v.check("read", true, im != nil && result != nil)
+	if !v.Pass {
+		return v
+	}
+	keyCounts := make(map[Int3Key]int, im.size)
+	for i := 0; i < im.size; i++ {
+		keyCounts[im.keys[i]]++
+	}
+	sameKeysCount := make(map[int]int32, 5)
+	for _, count := range keyCounts {
+		sameKeysCount[count]++
+	}
+	scan, err := scanDataFile(findDataFilename(name, size))
+	if err != nil {
+		v.check("data file scan", "", err.Error())
+	}
+	v.check("nb lines", result.NbLines, int32(scan.nbLines))
+	if scan.headerLines >= 0 {
+		v.check("header nb lines", int32(scan.headerLines), int32(scan.nbLines))
+	}
+	computed := newDataFileReport(im.size, sameKeysCount, scan.offsets)
+	v.check("nb entries", result.NbEntries, computed.NbEntries)
+	v.check("nb same keys", result.NbSameKeys, computed.NbSameKeys)
+	v.check("nb of times same key", nonNilInt32(result.NbOfTimesSameKey), nonNilInt32(computed.NbOfTimesSameKey))
+	v.check("offsets per threads", nonNilInt64(result.OffsetsPerThreads), computed.OffsetsPerThreads)
+
+	notKeysFound := 0
+	wrongIdx := 0
+	for i := 0; i < im.size; i++ {
+		if _, ok := keyCounts[im.getNotKey(i)]; ok {
+			notKeysFound++
+		}
+		if im.values[i].Idx != int64(i) {
+			wrongIdx++
+		}
+	}
+	v.check("not keys found", 0, notKeysFound)
+	v.check("values not sequential", 0, wrongIdx)
+	if result.ValueSizeDistribution != "" {
+		valueSizes := valueSizeStats{distribution: result.ValueSizeDistribution}
+		for i := 0; i < im.size; i++ {
+			valueSizes.add(len(im.values[i].SVal))
+		}
+		computed.Reset()
+		valueSizes.fill(computed)
+		v.check("value sizes", []int64{int64(result.MinValueSize), int64(result.MaxValueSize), result.TotalValueSize},
+			[]int64{int64(computed.MinValueSize), int64(computed.MaxValueSize), computed.TotalValueSize})
+	}
+	return v
+}
+
+func nonNilInt32(s []int32) []int32 {
+	if s == nil {
+		return []int32{}
+	}
+	return s
+}
+
+func nonNilInt64(s []int64) []int64 {
+	if s == nil {
+		return []int64{}
+	}
+	return s
+}
+
+// dataFileScan is what a sequential read of the data file found: the offset of each
+// thread segment, the number of records and the number of lines of the header, -1 without header
+type dataFileScan struct {
+	offsets     []int64
+	nbLines     int
+	headerLines int
+}
+
+// scanDataFile reads sequentially the data file and counts its records
+func scanDataFile(dataFilename string) (*dataFileScan, error) {
+	scan := &dataFileScan{offsets: make([]int64, 0, MaxConThreads), headerLines: -1}
+	r, err := openDataFile(dataFilename)
+	if err != nil {
+		return scan, err
+	}
+	defer r.close()
+	linesPerSegment := NbLinesPerThreads
+	if r.header != nil {
+		linesPerSegment = int(r.header.NbLinesPerBlock)
+		scan.headerLines = int(r.header.NbLines)
+	}
+	for {
+		pos := r.pos
+		data, err := r.nextRecord()
+		if err != nil {
+			return scan, err
+		}
+		if data == nil {
+			return scan, nil
+		}
+		if scan.nbLines%linesPerSegment == 0 {
+			scan.offsets = append(scan.offsets, pos)
+		}
+		scan.nbLines++
+	}
+}
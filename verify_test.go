@@ -0,0 +1,29 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanDataFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "scan.data")
+	size := 2*NbLinesPerThreads + 5
+	writeTestDataFile(t, filename, size, 16)
+
+	scan, err := scanDataFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, size, scan.nbLines)
+	assert.Equal(t, size, scan.headerLines)
+	assert.Equal(t, 3, len(scan.offsets))
+
+	// A truncated file has less records than its header
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filename, data[:scan.offsets[2]], 0644))
+	scan, err = scanDataFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*NbLinesPerThreads, scan.nbLines)
+	assert.Equal(t, size, scan.headerLines)
+}
@@ -0,0 +1,387 @@
+package maptester
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Key choice distributions of the workload mixes
+const (
+	UniformKeys   = "uniform"
+	ZipfianKeys   = "zipfian"
+	LatestKeys    = "latest"
+	ZipfianS      = 1.1
+	MaxScanLength = 100
+)
+
+type mixOp int
+
+const (
+	readOp mixOp = iota
+	insertOp
+	updateOp
+	readModifyWriteOp
+	deleteOp
+	scanOp
+	nbMixOps
+)
+
+// Letters used in the mix names for each operation
+const mixOpLetters = "riumds"
+
+// WorkloadMix is the percent of each operation the workers draw from.
+// The populate mix is the original test: write threads insert all lines while
+// read threads do random loads.
+type WorkloadMix struct {
+	name         string
+	percents     [nbMixOps]int
+	distribution string
+}
+
+var PopulateMix = &WorkloadMix{name: "populate"}
+
+// The YCSB core workloads A to F
+var YcsbMixes = []*WorkloadMix{
+	{name: "ycsbA", percents: [nbMixOps]int{readOp: 50, updateOp: 50}, distribution: ZipfianKeys},
+	{name: "ycsbB", percents: [nbMixOps]int{readOp: 95, updateOp: 5}, distribution: ZipfianKeys},
+	{name: "ycsbC", percents: [nbMixOps]int{readOp: 100}, distribution: ZipfianKeys},
+	{name: "ycsbD", percents: [nbMixOps]int{readOp: 95, insertOp: 5}, distribution: LatestKeys},
+	{name: "ycsbE", percents: [nbMixOps]int{scanOp: 95, insertOp: 5}, distribution: ZipfianKeys},
+	{name: "ycsbF", percents: [nbMixOps]int{readOp: 50, readModifyWriteOp: 50}, distribution: ZipfianKeys},
+}
+
+// Scenarios of the populate mix, the other mixes always use the steady scenario
+const (
+	// Readers start with the writers
+	InterleavedScenario = "interleaved"
+	// Readers start when all lines are inserted
+	PhasedScenario = "phased"
+	// Workers draw reads and writes on a populated map
+	SteadyScenario = "steady"
+)
+
+// Used in Run Configuration
+var WorkloadMixes = []*WorkloadMix{PopulateMix}
+var Scenarios = []string{InterleavedScenario, PhasedScenario}
+
+// ParseScenario checks the name is a scenario of the populate mix
+func ParseScenario(name string) (string, error) {
+	if name != InterleavedScenario && name != PhasedScenario {
+		return "", fmt.Errorf("scenario %q unknown, expected %s or %s", name, InterleavedScenario, PhasedScenario)
+	}
+	return name, nil
+}
+
+// scenarios returns the scenarios the mix is tested with
+func (mix *WorkloadMix) scenarios() []string {
+	if mix.isPopulate() {
+		return Scenarios
+	}
+	return []string{SteadyScenario}
+}
+
+func (mix *WorkloadMix) Name() string {
+	return mix.name
+}
+
+func (mix *WorkloadMix) String() string {
+	return mix.name
+}
+
+func (mix *WorkloadMix) isPopulate() bool {
+	return mix.name == PopulateMix.name
+}
+
+// hasWrites is true if workers modify the map, so a non concurrent map can only use one worker
+func (mix *WorkloadMix) hasWrites() bool {
+	return mix.isPopulate() || mix.percents[readOp]+mix.percents[scanOp] < 100
+}
+
+func (mix *WorkloadMix) hasDeletes() bool {
+	return mix.percents[deleteOp] > 0
+}
+
+// ParseWorkloadMix returns the preset of the name, or the mix of a name like r90i5d5-latest
+// made of the percent of each operation: r read, i insert, u update, m read-modify-write,
+// d delete, s scan, and an optional key distribution uniform, zipfian or latest.
+func ParseWorkloadMix(name string) (*WorkloadMix, error) {
+	if name == PopulateMix.name {
+		return PopulateMix, nil
+	}
+	for _, mix := range YcsbMixes {
+		if name == mix.name {
+			return mix, nil
+		}
+	}
+	mix := &WorkloadMix{distribution: UniformKeys}
+	spec := name
+	if idx := strings.IndexByte(name, '-'); idx >= 0 {
+		spec = name[:idx]
+		mix.distribution = name[idx+1:]
+	}
+	if mix.distribution != UniformKeys && mix.distribution != ZipfianKeys && mix.distribution != LatestKeys {
+		return nil, fmt.Errorf("unknown key distribution %q in workload mix %q", mix.distribution, name)
+	}
+	total := 0
+	for len(spec) > 0 {
+		op := strings.IndexByte(mixOpLetters, spec[0])
+		end := 1
+		for end < len(spec) && spec[end] >= '0' && spec[end] <= '9' {
+			end++
+		}
+		percent, err := strconv.Atoi(spec[1:end])
+		if op < 0 || err != nil || mix.percents[op] > 0 {
+			return nil, fmt.Errorf("invalid workload mix %q, expected a preset or operations like r90i5d5-latest", name)
+		}
+		mix.percents[op] = percent
+		total += percent
+		spec = spec[end:]
+	}
+	if total != 100 {
+		return nil, fmt.Errorf("operations of workload mix %q sum to %d percent", name, total)
+	}
+	mix.name = mix.specName()
+	return mix, nil
+}
+
+func (mix *WorkloadMix) specName() string {
+	var sb strings.Builder
+	for op, percent := range mix.percents {
+		if percent > 0 {
+			sb.WriteByte(mixOpLetters[op])
+			sb.WriteString(strconv.Itoa(percent))
+		}
+	}
+	sb.WriteByte('-')
+	sb.WriteString(mix.distribution)
+	return sb.String()
+}
+
+// nextOp returns the operation of a random percent between 0 and 99
+func (mix *WorkloadMix) nextOp(p int) mixOp {
+	for op, percent := range mix.percents {
+		if p < percent {
+			return mixOp(op)
+		}
+		p -= percent
+	}
+	return readOp
+}
+
+// nbLoaded returns the number of lines inserted before the workers start, keeping
+// the lines needed by the expected inserts, at most half of the data set.
+func (mix *WorkloadMix) nbLoaded(size int, totalOps int) int {
+	nbInserts := totalOps / 100 * mix.percents[insertOp]
+	if nbInserts > size/2 {
+		nbInserts = size / 2
+	}
+	return size - nbInserts
+}
+
+// keyChooser draws the line index of the keys used by a worker
+type keyChooser struct {
+	rnd          *rand.Rand
+	zipf         *rand.Zipf
+	distribution string
+}
+
+func newKeyChooser(rnd *rand.Rand, distribution string, maxRecords int) *keyChooser {
+	kc := &keyChooser{rnd: rnd, distribution: distribution}
+	if distribution != UniformKeys && maxRecords > 1 {
+		kc.zipf = rand.NewZipf(rnd, ZipfianS, 1, uint64(maxRecords-1))
+	}
+	return kc
+}
+
+// next returns a line index lower than nbRecords
+func (kc *keyChooser) next(nbRecords int) int {
+	if kc.zipf == nil {
+		return kc.rnd.Intn(nbRecords)
+	}
+	z := kc.zipf.Uint64()
+	if kc.distribution == LatestKeys {
+		// The most recent lines are the most popular
+		return nbRecords - 1 - int(z%uint64(nbRecords))
+	}
+	// Scrambled so the popular keys are spread over the data set
+	return int(mix64(z) % uint64(nbRecords))
+}
+
+// testWorkloadMix inserts the lines not reserved for the inserts with the write threads,
+// then each read thread executes nbReadTest operations drawn from the workload mix,
+// or draws operations for the test duration if set. The insert of the lines is not
+// measured with a test duration.
+func (mp *MapPerfTestResult) testWorkloadMix(m ConcurrentInt3Map, im *IntMapTestDataSet) {
+	conf := mp.runConf.testConf
+	mix := mp.runConf.mix
+	nbOps := conf.nbReadTest
+	totalOps := conf.nbReadThreads * conf.nbReadTest
+	if TestDuration > 0 {
+		nbOps = -1
+		totalOps = im.size
+	}
+	loaded := mix.nbLoaded(im.size, totalOps)
+	seeds := make([]int64, conf.nbReadThreads)
+	for i := range seeds {
+		seeds[i] = rand.Int63()
+	}
+	mp.harness = mp.harnessMode()
+
+	// The workers of the mix wait for the end of the load
+	nbWriteThreads := conf.nbWriteThreads
+	if !m.SupportConcurrentWrite() {
+		nbWriteThreads = 1
+	}
+	loadBarrier := newStartBarrier(nbWriteThreads)
+	loadWaitGroup := new(sync.WaitGroup)
+	loadWaitGroup.Add(nbWriteThreads)
+	for i := 0; i < nbWriteThreads; i++ {
+		offset, size := writeSegment(loaded, nbWriteThreads, i)
+		mp.startWorker(loadBarrier, true, loadWaitGroup, func() {
+			testLoadAndStore(m, im, offset, size, nil, mp)
+		})
+	}
+	mixBarrier := newStartBarrier(conf.nbReadThreads)
+	mixWaitGroup := new(sync.WaitGroup)
+	nextInsert := int64(loaded)
+	mixWaitGroup.Add(conf.nbReadThreads)
+	for i := 0; i < conf.nbReadThreads; i++ {
+		seed := seeds[i]
+		mp.startWorker(mixBarrier, false, mixWaitGroup, func() {
+			testMixWorker(m, im, mix, nbOps, seed, loaded, &nextInsert, mp)
+		})
+	}
+	loadBarrier.waitReady()
+	mixBarrier.waitReady()
+
+	if TestDuration == 0 {
+		mp.init()
+	}
+	loadStart := loadBarrier.release()
+	loadWaitGroup.Wait()
+	if TestDuration > 0 {
+		mp.init()
+		mp.targetDuration = TestDuration
+		elapsed := mp.runFor(mixBarrier, mixWaitGroup)
+		mp.phases.setMixed(int(mp.mixedOpsDone), elapsed)
+	} else {
+		mp.phases.setWrite(loaded, time.Since(loadStart))
+		runStart := mixBarrier.release()
+		mixWaitGroup.Wait()
+		mp.phases.setMixed(totalOps, time.Since(runStart))
+	}
+
+	mp.nbMapEntries = m.Size()
+	mp.stopWatch.stop()
+	if mix.hasDeletes() {
+		// Cannot be known
+		mp.nbExpectedMapEntries = mp.nbMapEntries
+	} else {
+		inserted := int(nextInsert)
+		if inserted > im.size {
+			inserted = im.size
+		}
+		mp.nbExpectedMapEntries = im.nbDistinctKeys(inserted)
+	}
+	mp.checkSize()
+	mp.measureHeap(m)
+	mp.display(mp.Name())
+}
+
+// nbDistinctKeys returns the number of different keys in the first lines of the data set
+func (im *IntMapTestDataSet) nbDistinctKeys(nbLines int) int {
+	keys := make(map[Int3Key]bool, nbLines)
+	for i := 0; i < nbLines; i++ {
+		keys[im.keys[i]] = true
+	}
+	return len(keys)
+}
+
+// testMixWorker executes nbOps operations of a workload mix, or until stopped if nbOps is negative.
+// Reads and scans use all the inserted lines, but updates and deletes only the loaded ones
+// so they never insert a line before its insert.
+func testMixWorker(m ConcurrentInt3Map, im *IntMapTestDataSet, mix *WorkloadMix, nbOps int, seed int64, loaded int, nextInsert *int64, perf *MapPerfTestResult) {
+	errorsKeyFound := int32(0)
+	errorsKeyNotFound := int32(0)
+	errorsKeyNotSame := int32(0)
+	rnd := rand.New(rand.NewSource(seed))
+	keys := newKeyChooser(rnd, mix.distribution, im.size)
+	percentMiss := perf.runConf.testConf.percentMiss
+	checkNotFound := !mix.hasDeletes()
+	loads := new(latencyHistogram)
+	stores := new(latencyHistogram)
+	load := func(idx int) {
+		value, ok := m.Load(im.keys[idx])
+		if ok {
+			if im.keys[int(value.value().Idx)] != im.keys[idx] {
+				errorsKeyNotSame++
+			}
+		} else if checkNotFound && idx < loaded {
+			errorsKeyNotFound++
+		}
+	}
+	untilStopped := nbOps < 0
+	i := 0
+	for ; untilStopped || i < nbOps; i++ {
+		if untilStopped && perf.isStopped(i) {
+			break
+		}
+		nbRecords := int(atomic.LoadInt64(nextInsert))
+		if nbRecords > im.size {
+			nbRecords = im.size
+		}
+		switch mix.nextOp(rnd.Intn(100)) {
+		case readOp:
+			idx := keys.next(nbRecords)
+			start := loads.start(i)
+			if percentMiss > 0 && rnd.Float32() < percentMiss {
+				if _, ok := m.Load(im.getNotKey(idx)); ok {
+					errorsKeyFound++
+				}
+			} else {
+				load(idx)
+			}
+			loads.end(start)
+		case insertOp:
+			idx := int(atomic.AddInt64(nextInsert, 1) - 1)
+			if idx >= im.size {
+				// All lines inserted, becomes an update
+				idx = keys.next(loaded)
+			}
+			start := stores.start(i)
+			m.LoadOrStore(im.keys[idx], &TestMapValue{val: &im.values[idx]})
+			stores.end(start)
+		case updateOp:
+			idx := keys.next(loaded)
+			m.Store(im.keys[idx], &TestMapValue{val: &im.values[idx]})
+		case readModifyWriteOp:
+			idx := keys.next(loaded)
+			newValue := &TestMapValue{val: &im.values[idx]}
+			if oldValue, ok := m.Load(im.keys[idx]); ok {
+				newValue.count = atomic.LoadUint32(&oldValue.count) + 1
+			}
+			m.Store(im.keys[idx], newValue)
+		case deleteOp:
+			m.Delete(im.keys[keys.next(loaded)])
+		case scanOp:
+			idx := keys.next(nbRecords)
+			length := 1 + rnd.Intn(MaxScanLength)
+			for j := 0; j < length; j++ {
+				load((idx + j) % nbRecords)
+			}
+		default:
+			logger.Fatalf("Operation %d not supported", i)
+		}
+	}
+	perf.latencies.add(loads, stores)
+	atomic.AddInt64(&perf.mixedOpsDone, int64(i))
+	atomic.AddInt32(&perf.errorsKeyFound, errorsKeyFound)
+	atomic.AddInt32(&perf.errorsKeyNotFound, errorsKeyNotFound)
+	atomic.AddInt32(&perf.errorsKeyNotSame, errorsKeyNotSame)
+}
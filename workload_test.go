@@ -0,0 +1,82 @@
+package maptester
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestParseWorkloadMix(t *testing.T) {
+	mix, err := ParseWorkloadMix("ycsbD")
+	assert.NoError(t, err)
+	assert.Equal(t, LatestKeys, mix.distribution)
+	assert.Equal(t, 5, mix.percents[insertOp])
+	mix, err = ParseWorkloadMix("populate")
+	assert.NoError(t, err)
+	assert.True(t, mix.isPopulate())
+	assert.True(t, mix.hasWrites())
+
+	mix, err = ParseWorkloadMix("d5r90i5")
+	assert.NoError(t, err)
+	assert.Equal(t, "r90i5d5-uniform", mix.Name())
+	assert.True(t, mix.hasDeletes())
+	mix, err = ParseWorkloadMix("r100-zipfian")
+	assert.NoError(t, err)
+	assert.False(t, mix.hasWrites())
+	for _, name := range []string{"", "ycsbG", "r90", "r50r50", "x100", "r-100", "r100-normal", "r50u"} {
+		_, err := ParseWorkloadMix(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestWorkloadMixNextOp(t *testing.T) {
+	mix, err := ParseWorkloadMix("r50i10u10m10d10s10")
+	assert.NoError(t, err)
+	counts := make(map[mixOp]int)
+	for p := 0; p < 100; p++ {
+		counts[mix.nextOp(p)]++
+	}
+	assert.Equal(t, map[mixOp]int{readOp: 50, insertOp: 10, updateOp: 10, readModifyWriteOp: 10, deleteOp: 10, scanOp: 10}, counts)
+	assert.Equal(t, 900, mix.nbLoaded(1000, 1000))
+	assert.Equal(t, 500, mix.nbLoaded(1000, 100000))
+}
+
+func TestKeyChooser(t *testing.T) {
+	for _, distribution := range []string{UniformKeys, ZipfianKeys, LatestKeys} {
+		kc := newKeyChooser(rand.New(rand.NewSource(1)), distribution, 1000)
+		counts := make(map[int]int)
+		for i := 0; i < 10000; i++ {
+			idx := kc.next(500)
+			assert.True(t, idx >= 0 && idx < 500)
+			counts[idx]++
+		}
+		if distribution == LatestKeys {
+			assert.True(t, counts[499] > 1000, "latest key used %d times", counts[499])
+		}
+		if distribution == UniformKeys {
+			assert.True(t, len(counts) > 450)
+		}
+	}
+}
+
+func TestWorkloadMixes(t *testing.T) {
+	size := 3000
+	im, report := newTestDataSet(size)
+	custom, err := ParseWorkloadMix("r40i20u10m10d10s10-zipfian")
+	assert.NoError(t, err)
+	for _, mix := range append(YcsbMixes, custom) {
+		rc := newTestRunConfiguration(size, 2, 3, 2000)
+		rc.mix = mix
+		rc.fillRunName()
+		for _, mt := range MapTypes {
+			if !mt.supports(rc) || (raceDetector && mt.name == "fredMap") {
+				continue
+			}
+			mp := &MapPerfTestResult{runConf: rc, mapTypeName: mt.name}
+			mp.fill(report)
+			mp.testConcurrentMap(im)
+			assert.Equal(t, 0, mp.NbErrors(), mp.Name())
+			assert.True(t, mp.nbMapEntries > 0, mp.Name())
+		}
+	}
+}